@@ -0,0 +1,500 @@
+package chip8
+
+
+import (
+	"fmt"
+	"sort"
+)
+
+
+// FindingKind is what part of a ROM a finding is about
+type FindingKind int
+
+const (
+	// the ROM does not fit into memory
+	FINDING_SIZE FindingKind = iota
+	// execution continues with something that is not an instruction
+	FINDING_TARGET
+	// machine code calls and memory used past its end
+	FINDING_MEMORY
+	// instructions only some platforms have
+	FINDING_PLATFORM
+	// returns, recursion and calls nesting too deep
+	FINDING_STACK
+)
+
+var FINDING_KIND_NAMES = map[FindingKind]string{
+	FINDING_SIZE: "size",
+	FINDING_TARGET: "target",
+	FINDING_MEMORY: "memory",
+	FINDING_PLATFORM: "platform",
+	FINDING_STACK: "stack",
+}
+
+
+func (kind FindingKind) String() string {
+	return FINDING_KIND_NAMES[kind]
+}
+
+
+// Finding is a problem or a platform specific instruction found in a ROM
+type Finding struct {
+	Address int
+	Opcode uint16
+	Kind FindingKind
+	Message string
+}
+
+// Analysis is what AnalyzeROM found out about the code reachable in a ROM
+// and the platform it most likely targets
+type Analysis struct {
+	Findings []Finding
+	// the variant the ROM was analyzed as
+	Variant Variant
+	// name of the quirk profile in QUIRK_PRESETS
+	Quirks string
+	// why the variant and quirks were picked
+	Reasons []string
+
+	flow *Flow
+	rom []byte
+}
+
+
+// AnalyzeROM guesses the variant a ROM targets and analyzes it as that
+// variant with AnalyzeROMAs
+func AnalyzeROM(rom []byte) *Analysis {
+	// all instructions of every variant are allowed while guessing, so the ROM
+	// shows which ones it needs
+	variant, reasons := guessVariant(FollowFlow(rom, VARIANT_XOCHIP))
+
+	analysis := AnalyzeROMAs(rom, variant)
+	analysis.Reasons = append(reasons, analysis.Reasons...)
+
+	return analysis
+}
+
+// AnalyzeROMAs follows the control flow of a ROM as variant and reports what
+// would fail or only work on some platforms
+func AnalyzeROMAs(rom []byte, variant Variant) *Analysis {
+	analysis := new(Analysis)
+	analysis.rom = rom
+	analysis.Variant = variant
+	analysis.flow = FollowFlow(rom, variant)
+
+	if len(rom) > variant.MemorySize() - PC_START {
+		analysis.report(FINDING_SIZE, PC_START, "The ROM is %d bytes, more than fits into the memory of %s", len(rom), variant)
+	}
+	analysis.checkTargets()
+	analysis.checkInstructions()
+	analysis.checkStack()
+	analysis.guessQuirks()
+
+	// findings come from maps, so every field takes part to keep the order
+	// the same between runs
+	sort.Slice(analysis.Findings, func(i int, j int) bool {
+		a := analysis.Findings[i]
+		b := analysis.Findings[j]
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Message < b.Message
+	})
+
+	return analysis
+}
+
+
+func (analysis *Analysis) report(kind FindingKind, address int, message string, args ...interface{}) {
+	analysis.Findings = append(analysis.Findings, Finding{
+		Address: address,
+		Opcode: readWord(analysis.rom, address - PC_START),
+		Kind: kind,
+		Message: fmt.Sprintf(message, args...),
+	})
+}
+
+// checkTargets reports where execution continues with something that is not
+// an instruction
+func (analysis *Analysis) checkTargets() {
+	flow := analysis.flow
+	end := PC_START + len(analysis.rom)
+
+	targets := map[int][]int{PC_START: nil}
+	for address, successors := range flow.Successors {
+		for _, successor := range successors {
+			targets[successor] = append(targets[successor], address)
+		}
+	}
+
+	for target, sources := range targets {
+		if _, ok := flow.Instructions[target]; ok {
+			continue
+		}
+
+		// report at the instruction leading there, if there is one
+		from := target
+		if len(sources) > 0 {
+			sort.Ints(sources)
+			from = sources[0]
+		}
+
+		switch {
+		case target >= XOCHIP_MEMORY_SIZE:
+			analysis.report(FINDING_TARGET, from, "Jumps to 0x%04X, outside of memory", target)
+			break
+
+		case target >= MEMORY_SIZE && analysis.Variant < VARIANT_XOCHIP:
+			analysis.report(FINDING_TARGET, from, "Jumps to 0x%04X, outside of the 4 KiB of memory of CHIP-8 and SUPER-CHIP", target)
+			break
+
+		case target < PC_START:
+			analysis.report(FINDING_TARGET, from, "Jumps to 0x%03X, into the interpreter", target)
+			break
+
+		case target >= end || target + 1 >= end:
+			analysis.report(FINDING_TARGET, from, "Jumps to 0x%03X, past the end of the ROM", target)
+			break
+
+		default:
+			inst := DecodeAt(analysis.rom, target - PC_START)
+			if !inst.Valid() {
+				analysis.report(FINDING_TARGET, target, "Unknown opcode 0x%04X", inst.Opcode)
+			} else if inst.Variant() > analysis.Variant {
+				analysis.report(FINDING_TARGET, target, "%s needs %s", inst.String(), inst.Variant())
+			} else if target + int(inst.Size()) > end {
+				analysis.report(FINDING_TARGET, target, "%s runs past the end of the ROM", inst.Mnemonic())
+			} else {
+				analysis.report(FINDING_TARGET, from, "Jumps to 0x%03X, into the middle of an instruction", target)
+			}
+			break
+		}
+	}
+}
+
+// checkInstructions reports machine code calls, computed jumps and accesses
+// through I that can leave memory, and instructions only some platforms have
+func (analysis *Analysis) checkInstructions() {
+	flow := analysis.flow
+	end := analysis.Variant.MemorySize()
+
+	// the first address and count of every platform specific instruction
+	first := make(map[string]int)
+	counts := make(map[string]int)
+
+	for _, address := range flow.Addresses() {
+		inst := flow.Instructions[address]
+
+		if inst.Op == OP_SYS && !inst.Halts() {
+			analysis.report(FINDING_MEMORY, address, "Calls machine code at 0x%03X, which is not supported", inst.Address)
+		}
+
+		if inst.Op == OP_JMP_V0 && int(inst.Address) + 0xFF >= end {
+			analysis.report(FINDING_MEMORY, address, "Jumps to 0x%03X plus V0, which can be past 0x%X", inst.Address, end - 1)
+		}
+
+		for _, region := range flow.Accesses[address] {
+			if region.End > end {
+				analysis.report(FINDING_MEMORY, address, "Uses memory up to 0x%04X through I, past 0x%X", region.End - 1, end - 1)
+			}
+		}
+
+		if inst.Variant() > VARIANT_CHIP8 {
+			name := fmt.Sprintf("%s instruction %s", inst.Variant(), inst.String())
+			if _, ok := first[name]; !ok {
+				first[name] = address
+			}
+			counts[name]++
+		}
+	}
+
+	for name, address := range first {
+		if counts[name] > 1 {
+			analysis.report(FINDING_PLATFORM, address, "Uses the %s, %d times", name, counts[name])
+		} else {
+			analysis.report(FINDING_PLATFORM, address, "Uses the %s", name)
+		}
+	}
+}
+
+// checkStack reports returns outside of subroutines, recursion and calls
+// nesting deeper than the stack
+func (analysis *Analysis) checkStack() {
+	flow := analysis.flow
+	routines := flow.Routines()
+
+	calls := make(map[int][]int)
+	for _, routine := range routines {
+		for _, block := range routine.Blocks {
+			last := block.Addresses[len(block.Addresses) - 1]
+			inst := flow.Instructions[last]
+
+			if inst.Op == OP_CALL {
+				calls[routine.Entry] = append(calls[routine.Entry], int(inst.Address))
+			}
+			if inst.Op == OP_RET && routine.Entry == PC_START {
+				analysis.report(FINDING_STACK, last, "Returns without a subroutine to return from")
+			}
+		}
+	}
+
+	// depth of the deepest chain of calls starting at each routine
+	depths := make(map[int]int)
+	active := make(map[int]bool)
+	recursive := make(map[int]bool)
+	var depth func(entry int) int
+	depth = func(entry int) int {
+		if active[entry] {
+			if !recursive[entry] {
+				recursive[entry] = true
+				analysis.report(FINDING_STACK, entry, "%s calls itself, the stack can overflow", routineName(entry))
+			}
+			return 0
+		}
+		if known, ok := depths[entry]; ok {
+			return known
+		}
+
+		active[entry] = true
+		deepest := 0
+		for _, callee := range calls[entry] {
+			if nested := depth(callee) + 1; nested > deepest {
+				deepest = nested
+			}
+		}
+		active[entry] = false
+
+		depths[entry] = deepest
+		return deepest
+	}
+
+	if deepest := depth(PC_START); deepest > STACK_SIZE {
+		analysis.report(FINDING_STACK, PC_START, "Calls nest %d deep, but the stack holds %d", deepest, STACK_SIZE)
+	}
+}
+
+// guessVariant picks the oldest variant that has every instruction reached in
+// flow and the memory to hold the ROM, along with why
+func guessVariant(flow *Flow) (Variant, []string) {
+	variant := VARIANT_CHIP8
+	reasons := []string{}
+
+	for _, address := range flow.Addresses() {
+		inst := flow.Instructions[address]
+		if inst.Variant() > variant {
+			variant = inst.Variant()
+			reasons = append(reasons, fmt.Sprintf("%s at 0x%03X needs %s", inst.String(), address, inst.Variant()))
+		}
+	}
+
+	if variant < VARIANT_XOCHIP && len(flow.rom) > MEMORY_SIZE - PC_START {
+		variant = VARIANT_XOCHIP
+		reasons = append(reasons, fmt.Sprintf("the ROM is %d bytes, more than fits into 4 KiB of memory", len(flow.rom)))
+	}
+
+	return variant, reasons
+}
+
+// quirk profiles in QUIRK_PRESETS that fit each variant, the first is picked
+// when the ROM does not show what it relies on
+var QUIRK_CANDIDATES = map[Variant][]string{
+	VARIANT_CHIP8: []string{"chip8", "vip", "chip48"},
+	VARIANT_SCHIP: []string{"schip"},
+	VARIANT_XOCHIP: []string{"xochip"},
+}
+
+
+// quirkNeed is a quirk the ROM relies on, shown by the instruction in reason
+type quirkNeed struct {
+	name string
+	value bool
+	reason string
+}
+
+func (need quirkNeed) matches(quirks Quirks) bool {
+	switch need.name {
+	case "shift":
+		return quirks.Shift == need.value
+	case "load/store":
+		return quirks.LoadStore == need.value
+	case "jump":
+		return quirks.Jump == need.value
+	case "clipping":
+		return quirks.Clipping == need.value
+	}
+
+	return true
+}
+
+
+// guessQuirks picks the quirk profile of the variant that fits most of what
+// the ROM relies on
+func (analysis *Analysis) guessQuirks() {
+	needs := analysis.quirkNeeds()
+
+	best := -1
+	for _, name := range QUIRK_CANDIDATES[analysis.Variant] {
+		fits := 0
+		for _, need := range needs {
+			if need.matches(QUIRK_PRESETS[name]) {
+				fits++
+			}
+		}
+
+		if fits > best {
+			analysis.Quirks = name
+			best = fits
+		}
+	}
+
+	for _, need := range needs {
+		if need.matches(QUIRK_PRESETS[analysis.Quirks]) {
+			analysis.Reasons = append(analysis.Reasons, need.reason)
+		} else {
+			analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("%s, which %s does not do", need.reason, analysis.Quirks))
+		}
+	}
+}
+
+// quirkNeeds looks for the first instruction showing how the ROM relies on
+// each quirk:
+//   - 8XY6 and 8XYE with different registers shift Vy, without the shift quirk
+//   - FX55 or FX65 following another expects I to have moved
+//   - BNNN after setting only one of V0 and VX shows which one it adds, a
+//     CHIP-8 ROM not showing it is taken to add V0
+//   - DXYN at a position known from the block that crosses the edge of the
+//     display relies on wrapping
+func (analysis *Analysis) quirkNeeds() []quirkNeed {
+	flow := analysis.flow
+	found := make(map[string]quirkNeed)
+	need := func(name string, value bool, reason string, args ...interface{}) {
+		if _, ok := found[name]; !ok {
+			found[name] = quirkNeed{name: name, value: value, reason: fmt.Sprintf(reason, args...)}
+		}
+	}
+
+	// the display size is only known when the resolution never changes
+	resizes := false
+	for _, inst := range flow.Instructions {
+		if inst.Op == OP_HIGH || inst.Op == OP_LOW {
+			resizes = true
+		}
+	}
+
+	blocks := flow.Blocks()
+	starts := make([]int, 0, len(blocks))
+	for start := range blocks {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	for _, start := range starts {
+		// registers set to a known value in the block so far
+		known := make(map[uint8]int)
+
+		for _, address := range blocks[start].Addresses {
+			inst := flow.Instructions[address]
+			x := inst.X
+			y := inst.Y
+
+			switch inst.Op {
+			case OP_SHR, OP_SHL:
+				if x != y {
+					need("shift", false, "%s at 0x%03X shifts Vy", inst.String(), address)
+				}
+				break
+
+			case OP_STORE, OP_LOAD:
+				next, ok := flow.Instructions[address + 2]
+				if ok && next.Op == inst.Op {
+					need("load/store", true, "%s at 0x%03X follows another without setting I", next.String(), address + 2)
+				}
+				break
+
+			case OP_JMP_V0:
+				// with BX00 to BXFF both add the same register
+				high := uint8(inst.Address >> 8)
+				if high == 0 {
+					break
+				}
+
+				_, setV0 := known[0]
+				_, setVx := known[high]
+				if setVx && !setV0 {
+					need("jump", true, "%s at 0x%03X follows setting V%X", inst.String(), address, high)
+				} else if (setV0 && !setVx) || analysis.Variant == VARIANT_CHIP8 {
+					need("jump", false, "%s at 0x%03X adds V0", inst.String(), address)
+				}
+				break
+
+			case OP_DRW:
+				column, knownX := known[x]
+				row, knownY := known[y]
+				if resizes || inst.N == 0 || !knownX || !knownY {
+					break
+				}
+
+				column %= DISPLAY_WIDTH
+				row %= DISPLAY_HEIGHT
+				if column + 8 > DISPLAY_WIDTH || row + int(inst.N) > DISPLAY_HEIGHT {
+					need("clipping", false, "%s at 0x%03X draws across the edge at (%d, %d)", inst.String(), address, column, row)
+				}
+				break
+			}
+
+			trackRegisters(inst, known)
+		}
+	}
+
+	needs := make([]quirkNeed, 0, len(found))
+	for _, name := range []string{"shift", "load/store", "jump", "clipping"} {
+		if need, ok := found[name]; ok {
+			needs = append(needs, need)
+		}
+	}
+
+	return needs
+}
+
+// trackRegisters updates the registers with a known value after inst
+func trackRegisters(inst Instruction, known map[uint8]int) {
+	switch inst.Op {
+	case OP_LD_BYTE:
+		known[inst.X] = int(inst.K)
+		break
+
+	case OP_ADD_BYTE:
+		if value, ok := known[inst.X]; ok {
+			known[inst.X] = (value + int(inst.K)) & 0xFF
+		}
+		break
+
+	case OP_LD_REG, OP_OR, OP_AND, OP_XOR, OP_ADD_REG, OP_SUB, OP_SHR, OP_SUBN, OP_SHL:
+		delete(known, inst.X)
+		delete(known, 0xF)
+		break
+
+	case OP_RND, OP_LD_VX_DT, OP_LD_VX_K:
+		delete(known, inst.X)
+		break
+
+	case OP_DRW:
+		delete(known, 0xF)
+		break
+
+	case OP_LOAD, OP_LD_VX_R:
+		for i := uint8(0); i <= inst.X; i++ {
+			delete(known, i)
+		}
+		break
+
+	case OP_LOAD_RANGE:
+		for i := inst.X; i <= inst.Y; i++ {
+			delete(known, i)
+		}
+		break
+	}
+}
@@ -0,0 +1,69 @@
+package chip8
+
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+
+func TestAnalyzeROMOrdersFindings(t *testing.T) {
+	// HIGH, then a skip with both successors past the end of the ROM
+	rom := []byte{0x00, 0xFF, 0x30, 0x00}
+
+	first := AnalyzeROMAs(rom, VARIANT_SCHIP).Findings
+	if len(first) != 3 {
+		t.Fatalf("Got %d findings, expected 3: %+v", len(first), first)
+	}
+	if first[0].Kind != FINDING_PLATFORM || first[1].Message >= first[2].Message {
+		t.Errorf("Findings are out of order: %+v", first)
+	}
+
+	for i := 0; i < 20; i++ {
+		findings := AnalyzeROMAs(rom, VARIANT_SCHIP).Findings
+		if !reflect.DeepEqual(findings, first) {
+			t.Fatalf("Findings changed between runs: %+v, then %+v", first, findings)
+		}
+	}
+}
+
+func TestAnalyzeROMGuessesQuirks(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+		quirks string
+		// text one of the reasons has to contain
+		reason string
+	}{
+		{"nothing", []byte{0x12, 0x00}, "chip8", ""},
+		{"shifting Vx", []byte{0x81, 0x16, 0x12, 0x02}, "chip8", ""},
+		{"shifting Vy", []byte{0x81, 0x26, 0x12, 0x02}, "vip", "SHR V1, V2 at 0x200 shifts Vy"},
+		{"storing twice", []byte{0xF1, 0x55, 0xF1, 0x55, 0x12, 0x04}, "vip", "follows another without setting I"},
+		{"jumping by V0", []byte{0xB2, 0x02, 0x12, 0x02}, "chip8", "adds V0"},
+		{"jumping by VX", []byte{0x62, 0x02, 0xB2, 0x02, 0x12, 0x04}, "chip48", "follows setting V2"},
+		{"wrapping", []byte{
+			0x60, 0x3C, 0x61, 0x00, 0xA2, 0x0A, 0xD0, 0x11,
+			0x12, 0x08, 0xFF,
+		}, "chip8", "draws across the edge at (60, 0)"},
+		{"shifting Vy and wrapping", []byte{
+			0x60, 0x3C, 0x61, 0x00, 0xA2, 0x0C, 0xD0, 0x11,
+			0x81, 0x26, 0x12, 0x0A, 0xFF,
+		}, "chip8", "shifts Vy, which chip8 does not do"},
+		{"SUPER-CHIP", []byte{0x00, 0xFF, 0x12, 0x02}, "schip", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis := AnalyzeROM(test.rom)
+			if analysis.Quirks != test.quirks {
+				t.Errorf("Guessed %s, expected %s (%s)", analysis.Quirks, test.quirks, strings.Join(analysis.Reasons, ", "))
+			}
+
+			reasons := strings.Join(analysis.Reasons, ", ")
+			if !strings.Contains(reasons, test.reason) {
+				t.Errorf("Reasons %q do not contain %q", reasons, test.reason)
+			}
+		})
+	}
+}
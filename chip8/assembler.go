@@ -0,0 +1,884 @@
+package chip8
+
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+
+// operands which are written literally, and can't be used as names
+var RESERVED_NAMES = map[string]bool{
+	"[I]": true,
+	"I": true,
+	"DT": true,
+	"K": true,
+	"ST": true,
+	"F": true,
+	"B": true,
+	"HF": true,
+	"R": true,
+}
+
+// how deeply files can include each other
+const MAX_INCLUDE_DEPTH = 16
+
+
+// AssemblyError is a problem with a line of source
+type AssemblyError struct {
+	File string
+	Line int
+	Message string
+}
+
+
+func (err *AssemblyError) Error() string {
+	if err.Line == 0 {
+		return fmt.Sprintf("%s: %s", err.File, err.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Message)
+}
+
+
+// AssemblyErrors are all of the problems found in a source, one per line
+type AssemblyErrors []*AssemblyError
+
+
+func (errs AssemblyErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+
+type statementKind int
+
+const (
+	STATEMENT_INSTRUCTION statementKind = iota
+	STATEMENT_DB
+	STATEMENT_DW
+)
+
+// statement is an instruction or data directive placed at an address
+type statement struct {
+	kind statementKind
+	file string
+	line int
+	address int
+
+	mnemonic string
+	operands []string
+}
+
+// constant is a name defined with = or equ, evaluated when first used
+type constant struct {
+	expression string
+
+	evaluating bool
+	evaluated bool
+	value int
+}
+
+
+// assembler turns source into a ROM in two passes. The first places the
+// statements and labels, the second evaluates operands and encodes them.
+type assembler struct {
+	variant Variant
+	memorySize int
+
+	labels map[string]int
+	// label names in the order they were defined
+	labelOrder []string
+	constants map[string]*constant
+	statements []statement
+
+	// address of the next statement
+	address int
+	// highest address written to, plus one
+	end int
+
+	// files being read, to catch include cycles
+	including []string
+	// every file read, in order, to sort errors by
+	files []string
+
+	errors AssemblyErrors
+}
+
+
+// Assemble reads the source at path, along with the files it includes, and
+// returns the ROM and a source map of it. Only instructions of variant and
+// earlier variants are accepted.
+//
+// Every line holds a label, a statement or both, followed by an optional
+// comment starting with a semicolon:
+//
+//	loop: LD V0, 0x10    ; the same mnemonics as the disassembly
+//	SPEED = 3            ; or SPEED equ 3, a constant
+//	org 0x300            ; continue at an address
+//	include "sprites.asm"
+//	db 0xF0, 0x90, "text"
+//	dw 0x1234, loop
+//
+// Operands can be expressions of numbers (decimal, 0x hexadecimal, 0b binary
+// or 'c' characters), labels and constants with the C operators + - * / %
+// & | ^ ~ << >> and parentheses.
+func Assemble(path string, variant Variant) ([]byte, *SourceMap, error) {
+	asm := new(assembler)
+	asm.variant = variant
+	asm.memorySize = variant.MemorySize()
+	asm.labels = make(map[string]int)
+	asm.constants = make(map[string]*constant)
+	asm.address = PC_START
+	asm.end = PC_START
+
+	// the second pass runs even if the first failed, to report all errors
+	asm.readFile(path, "", 0)
+
+	rom := make([]byte, asm.end - PC_START)
+	sourceMap := NewSourceMap()
+	// the first of several labels at an address names it
+	for _, name := range asm.labelOrder {
+		address := uint16(asm.labels[name])
+		if _, ok := sourceMap.Symbols[address]; !ok {
+			sourceMap.Symbols[address] = name
+		}
+	}
+
+	for _, stmt := range asm.statements {
+		data := asm.encode(stmt)
+		copy(rom[stmt.address - PC_START:], data)
+
+		if len(data) > 0 {
+			sourceMap.Lines[uint16(stmt.address)] = SourceLine{File: stmt.file, Line: stmt.line}
+		}
+	}
+
+	if len(asm.errors) > 0 {
+		asm.sortErrors()
+		return nil, nil, asm.errors
+	}
+
+	return rom, sourceMap, nil
+}
+
+// sortErrors orders the errors of both passes by file and line
+func (asm *assembler) sortErrors() {
+	order := func(file string) int {
+		for i, other := range asm.files {
+			if other == file {
+				return i
+			}
+		}
+		return -1
+	}
+
+	sort.SliceStable(asm.errors, func(i int, j int) bool {
+		a := asm.errors[i]
+		b := asm.errors[j]
+		if a.File != b.File {
+			return order(a.File) < order(b.File)
+		}
+		return a.Line < b.Line
+	})
+}
+
+func (asm *assembler) fail(file string, line int, format string, args ...interface{}) {
+	asm.errors = append(asm.errors, &AssemblyError{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// readFile runs the first pass over a file. Included files are named
+// relative to the file including them.
+func (asm *assembler) readFile(path string, from string, fromLine int) {
+	if len(asm.including) >= MAX_INCLUDE_DEPTH {
+		asm.fail(from, fromLine, "Includes are nested too deeply")
+		return
+	}
+	for _, including := range asm.including {
+		if including == path {
+			asm.fail(from, fromLine, "%s includes itself", path)
+			return
+		}
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		if from == "" {
+			asm.errors = append(asm.errors, &AssemblyError{File: path, Message: err.Error()})
+		} else {
+			asm.fail(from, fromLine, "%v", err)
+		}
+		return
+	}
+
+	asm.including = append(asm.including, path)
+	asm.files = append(asm.files, path)
+	for i, text := range strings.Split(string(source), "\n") {
+		asm.readLine(path, i + 1, text)
+	}
+	asm.including = asm.including[:len(asm.including) - 1]
+}
+
+func (asm *assembler) readLine(file string, line int, text string) {
+	text = strings.TrimSpace(stripComment(text))
+
+	// label
+	if colon := strings.Index(text, ":"); colon >= 0 && isName(strings.TrimSpace(text[:colon])) {
+		name := strings.TrimSpace(text[:colon])
+		if asm.defined(name) {
+			asm.fail(file, line, "%s is already defined", name)
+		} else if isReserved(name) {
+			asm.fail(file, line, "%s is reserved and can't be a label", name)
+		} else {
+			asm.labels[name] = asm.address
+			asm.labelOrder = append(asm.labelOrder, name)
+		}
+
+		text = strings.TrimSpace(text[colon + 1:])
+	}
+
+	if text == "" {
+		return
+	}
+
+	word, rest := splitWord(text)
+	rest = strings.TrimSpace(rest)
+
+	// constants, NAME = EXPR or NAME equ EXPR
+	if next, value := splitWord(rest); strings.HasPrefix(rest, "=") || strings.ToLower(next) == "equ" {
+		if strings.HasPrefix(rest, "=") {
+			value = rest[1:]
+		}
+
+		if !isName(word) {
+			asm.fail(file, line, "Invalid constant name %q", word)
+		} else if asm.defined(word) {
+			asm.fail(file, line, "%s is already defined", word)
+		} else if isReserved(word) {
+			asm.fail(file, line, "%s is reserved and can't be a constant", word)
+		} else {
+			asm.constants[word] = &constant{expression: strings.TrimSpace(value)}
+		}
+		return
+	}
+
+	stmt := statement{file: file, line: line, address: asm.address, operands: splitOperands(rest)}
+	size := 0
+
+	switch strings.ToLower(word) {
+	case "include":
+		name, err := strconv.Unquote(rest)
+		if err != nil {
+			asm.fail(file, line, "Expected a quoted file name to include")
+			return
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(file), name)
+		}
+
+		asm.readFile(name, file, line)
+		return
+
+	case "org":
+		address, err := asm.evaluate(rest)
+		if err != nil {
+			asm.fail(file, line, "%v", err)
+			return
+		}
+		if address < asm.address {
+			asm.fail(file, line, "org 0x%X is before the current address 0x%X", address, asm.address)
+			return
+		}
+
+		asm.address = address
+		return
+
+	case "db":
+		stmt.kind = STATEMENT_DB
+		for _, operand := range stmt.operands {
+			if text, err := strconv.Unquote(operand); err == nil {
+				size += len(text)
+			} else {
+				size++
+			}
+		}
+		break
+
+	case "dw":
+		stmt.kind = STATEMENT_DW
+		size = 2 * len(stmt.operands)
+		break
+
+	default:
+		stmt.kind = STATEMENT_INSTRUCTION
+		stmt.mnemonic = strings.ToUpper(word)
+
+		known := false
+		for _, spec := range OP_SPECS {
+			known = known || spec.mnemonic == stmt.mnemonic
+		}
+		if !known {
+			asm.fail(file, line, "Unknown instruction %s", word)
+			return
+		}
+
+		size = 2
+		if stmt.mnemonic == "LONG" {
+			size = 4
+		}
+		break
+	}
+
+	if (stmt.kind == STATEMENT_DB || stmt.kind == STATEMENT_DW) && len(stmt.operands) == 0 {
+		asm.fail(file, line, "%s needs at least one value", word)
+		return
+	}
+
+	if asm.address + size > asm.memorySize {
+		asm.fail(file, line, "The program does not fit in %d bytes of memory", asm.memorySize)
+		return
+	}
+
+	asm.statements = append(asm.statements, stmt)
+	asm.address += size
+	if asm.address > asm.end {
+		asm.end = asm.address
+	}
+}
+
+func (asm *assembler) defined(name string) bool {
+	_, label := asm.labels[name]
+	_, constant := asm.constants[name]
+
+	return label || constant
+}
+
+// encode runs the second pass over a statement
+func (asm *assembler) encode(stmt statement) []byte {
+	switch stmt.kind {
+	case STATEMENT_DB:
+		data := []byte{}
+		for _, operand := range stmt.operands {
+			if text, err := strconv.Unquote(operand); err == nil {
+				data = append(data, text...)
+				continue
+			}
+
+			value, err := asm.evaluateIn(stmt, operand, -0x80, 0xFF)
+			if err != nil {
+				return nil
+			}
+			data = append(data, byte(value))
+		}
+		return data
+
+	case STATEMENT_DW:
+		data := []byte{}
+		for _, operand := range stmt.operands {
+			value, err := asm.evaluateIn(stmt, operand, -0x8000, 0xFFFF)
+			if err != nil {
+				return nil
+			}
+			data = append(data, byte(value >> 8), byte(value))
+		}
+		return data
+	}
+
+	spec, err := asm.match(stmt)
+	if err != nil {
+		asm.fail(stmt.file, stmt.line, "%v", err)
+		return nil
+	}
+
+	opcode := spec.pattern
+	long := -1
+	for i, kind := range spec.operands {
+		operand := stmt.operands[i]
+
+		switch kind {
+		case "Vx":
+			opcode |= uint16(registerNumber(operand)) << 8
+			break
+
+		case "Vy":
+			opcode |= uint16(registerNumber(operand)) << 4
+			break
+
+		case "nnn":
+			value, err := asm.evaluateIn(stmt, operand, 0, 0xFFF)
+			if err != nil {
+				return nil
+			}
+			opcode |= uint16(value)
+			break
+
+		case "nnnn":
+			value, err := asm.evaluateIn(stmt, operand, 0, 0xFFFF)
+			if err != nil {
+				return nil
+			}
+			long = value
+			break
+
+		case "kk":
+			value, err := asm.evaluateIn(stmt, operand, -0x80, 0xFF)
+			if err != nil {
+				return nil
+			}
+			opcode |= uint16(value) & 0xFF
+			break
+
+		case "n":
+			value, err := asm.evaluateIn(stmt, operand, 0, 0xF)
+			if err != nil {
+				return nil
+			}
+			opcode |= uint16(value)
+			break
+
+		case "x":
+			value, err := asm.evaluateIn(stmt, operand, 0, 0xF)
+			if err != nil {
+				return nil
+			}
+			opcode |= uint16(value) << 8
+			break
+		}
+	}
+
+	data := []byte{byte(opcode >> 8), byte(opcode)}
+	if long >= 0 {
+		data = append(data, byte(long >> 8), byte(long))
+	}
+
+	return data
+}
+
+// match finds the form of the instruction the operands were written for
+func (asm *assembler) match(stmt statement) (*opSpec, error) {
+	for i := range OP_SPECS {
+		spec := &OP_SPECS[i]
+		if spec.mnemonic != stmt.mnemonic || len(spec.operands) != len(stmt.operands) {
+			continue
+		}
+
+		matches := true
+		for j, kind := range spec.operands {
+			operand := stmt.operands[j]
+
+			switch kind {
+			case "Vx", "Vy":
+				matches = matches && registerNumber(operand) >= 0
+				break
+
+			case "nnn", "nnnn", "kk", "n", "x":
+				matches = matches && registerNumber(operand) < 0 && !isReserved(literal(operand))
+				break
+
+			default:
+				matches = matches && literal(operand) == kind
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		if spec.variant > asm.variant {
+			return nil, fmt.Errorf("%s %s needs the %s variant", spec.mnemonic, strings.Join(spec.operands, ", "), spec.variant)
+		}
+		return spec, nil
+	}
+
+	forms := []string{}
+	for _, spec := range OP_SPECS {
+		if spec.mnemonic == stmt.mnemonic {
+			forms = append(forms, strings.TrimSpace(spec.mnemonic + " " + strings.Join(spec.operands, ", ")))
+		}
+	}
+
+	return nil, fmt.Errorf("Invalid operands for %s, expected one of: %s", stmt.mnemonic, strings.Join(forms, "; "))
+}
+
+// evaluateIn evaluates an operand of a statement, reporting errors and values
+// outside of min to max
+func (asm *assembler) evaluateIn(stmt statement, expression string, min int, max int) (int, error) {
+	value, err := asm.evaluate(expression)
+	if err == nil && (value < min || value > max) {
+		if _, numberErr := parseNumber(expression); numberErr == nil {
+			err = fmt.Errorf("%s is out of range, expected %d to 0x%X", expression, min, max)
+		} else {
+			err = fmt.Errorf("%s is %d, out of range, expected %d to 0x%X", expression, value, min, max)
+		}
+	}
+
+	if err != nil {
+		asm.fail(stmt.file, stmt.line, "%v", err)
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// evaluate computes an expression
+func (asm *assembler) evaluate(expression string) (int, error) {
+	parser := expressionParser{asm: asm, tokens: tokenize(expression), text: expression}
+	if len(parser.tokens) == 0 {
+		return 0, fmt.Errorf("Expected an expression")
+	}
+
+	value, err := parser.parse(0)
+	if err != nil {
+		return 0, err
+	}
+	if parser.position < len(parser.tokens) {
+		return 0, fmt.Errorf("Unexpected %q in %q", parser.tokens[parser.position], expression)
+	}
+
+	return value, nil
+}
+
+// symbol looks up a label or evaluates a constant
+func (asm *assembler) symbol(name string) (int, error) {
+	if address, ok := asm.labels[name]; ok {
+		return address, nil
+	}
+
+	value, ok := asm.constants[name]
+	if !ok {
+		return 0, fmt.Errorf("Undefined name %s", name)
+	}
+
+	if !value.evaluated {
+		if value.evaluating {
+			return 0, fmt.Errorf("%s is defined in terms of itself", name)
+		}
+
+		value.evaluating = true
+		result, err := asm.evaluate(value.expression)
+		value.evaluating = false
+		if err != nil {
+			return 0, err
+		}
+
+		value.value = result
+		value.evaluated = true
+	}
+
+	return value.value, nil
+}
+
+
+// binary operators from the loosest to the tightest binding
+var PRECEDENCE = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// expressionParser evaluates an expression by recursive descent
+type expressionParser struct {
+	asm *assembler
+	tokens []string
+	position int
+	text string
+}
+
+
+func (parser *expressionParser) peek() string {
+	if parser.position < len(parser.tokens) {
+		return parser.tokens[parser.position]
+	}
+
+	return ""
+}
+
+func (parser *expressionParser) next() string {
+	token := parser.peek()
+	parser.position++
+
+	return token
+}
+
+// parse evaluates operators of the given precedence level and tighter ones
+func (parser *expressionParser) parse(level int) (int, error) {
+	if level == len(PRECEDENCE) {
+		return parser.unary()
+	}
+
+	left, err := parser.parse(level + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		operator := parser.peek()
+		found := false
+		for _, candidate := range PRECEDENCE[level] {
+			found = found || operator == candidate
+		}
+		if !found {
+			return left, nil
+		}
+		parser.next()
+
+		right, err := parser.parse(level + 1)
+		if err != nil {
+			return 0, err
+		}
+
+		switch operator {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<", ">>":
+			if right < 0 || right > 31 {
+				return 0, fmt.Errorf("Invalid shift by %d in %q", right, parser.text)
+			}
+			if operator == "<<" {
+				left <<= uint(right)
+			} else {
+				left >>= uint(right)
+			}
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				return 0, fmt.Errorf("Division by zero in %q", parser.text)
+			}
+			if operator == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+}
+
+func (parser *expressionParser) unary() (int, error) {
+	token := parser.next()
+
+	switch {
+	case token == "":
+		return 0, fmt.Errorf("Unexpected end of %q", parser.text)
+
+	case token == "-", token == "~", token == "+":
+		value, err := parser.unary()
+		if err != nil {
+			return 0, err
+		}
+		if token == "-" {
+			return -value, nil
+		}
+		if token == "~" {
+			return ^value, nil
+		}
+		return value, nil
+
+	case token == "(":
+		value, err := parser.parse(0)
+		if err != nil {
+			return 0, err
+		}
+		if parser.next() != ")" {
+			return 0, fmt.Errorf("Missing ) in %q", parser.text)
+		}
+		return value, nil
+
+	case strings.HasPrefix(token, "'"):
+		text, err := strconv.Unquote(token)
+		if err != nil || len(text) != 1 {
+			return 0, fmt.Errorf("Invalid character %s", token)
+		}
+		return int(text[0]), nil
+
+	case token[0] >= '0' && token[0] <= '9':
+		return parseNumber(token)
+
+	case isName(token):
+		return parser.asm.symbol(token)
+	}
+
+	return 0, fmt.Errorf("Unexpected %q in %q", token, parser.text)
+}
+
+
+// tokenize splits an expression into numbers, names, quoted characters and
+// operators
+func tokenize(expression string) []string {
+	tokens := []string{}
+
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+
+		case isNameCharacter(ch):
+			start := i
+			for i < len(expression) && isNameCharacter(expression[i]) {
+				i++
+			}
+			tokens = append(tokens, expression[start:i])
+
+		case ch == '\'':
+			end := strings.IndexByte(expression[i + 1:], '\'')
+			if end < 0 {
+				tokens = append(tokens, expression[i:])
+				return tokens
+			}
+			tokens = append(tokens, expression[i:i + end + 2])
+			i += end + 2
+
+		case strings.HasPrefix(expression[i:], "<<") || strings.HasPrefix(expression[i:], ">>"):
+			tokens = append(tokens, expression[i:i + 2])
+			i += 2
+
+		default:
+			tokens = append(tokens, string(ch))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// parseNumber reads decimal, 0x hexadecimal and 0b binary numbers
+func parseNumber(token string) (int, error) {
+	text := strings.ToLower(token)
+	base := 10
+	if strings.HasPrefix(text, "0x") {
+		text = text[2:]
+		base = 16
+	} else if strings.HasPrefix(text, "0b") {
+		text = text[2:]
+		base = 2
+	}
+
+	value, err := strconv.ParseInt(text, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid number %s", token)
+	}
+
+	return int(value), nil
+}
+
+func isNameCharacter(ch byte) bool {
+	return ch == '_' || ch == '.' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// isName reports whether text can be a label or constant name
+func isName(text string) bool {
+	if text == "" || (text[0] >= '0' && text[0] <= '9') {
+		return false
+	}
+
+	for i := 0; i < len(text); i++ {
+		if !isNameCharacter(text[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isReserved reports whether a name is a register or a literal operand
+func isReserved(name string) bool {
+	return registerNumber(name) >= 0 || RESERVED_NAMES[literal(name)]
+}
+
+// registerNumber is the number of a V register operand, or -1
+func registerNumber(operand string) int {
+	if len(operand) != 2 || (operand[0] != 'V' && operand[0] != 'v') {
+		return -1
+	}
+
+	number, err := strconv.ParseUint(operand[1:], 16, 8)
+	if err != nil {
+		return -1
+	}
+
+	return int(number)
+}
+
+// literal normalizes an operand for comparing with literal operands
+func literal(operand string) string {
+	return strings.ToUpper(strings.Replace(operand, " ", "", -1))
+}
+
+// stripComment removes a comment, leaving semicolons in quotes alone
+func stripComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote != 0 && line[i] == '\\':
+			i++
+		case quote != 0 && line[i] == quote:
+			quote = 0
+		case quote == 0 && (line[i] == '"' || line[i] == '\''):
+			quote = line[i]
+		case quote == 0 && line[i] == ';':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// splitWord splits off the first whitespace separated word
+func splitWord(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	index := strings.IndexAny(text, " \t")
+	if index < 0 {
+		return text, ""
+	}
+
+	return text[:index], text[index:]
+}
+
+// splitOperands splits operands on commas outside of quotes
+func splitOperands(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	operands := []string{}
+	quote := byte(0)
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote != 0 && text[i] == '\\':
+			i++
+		case quote != 0 && text[i] == quote:
+			quote = 0
+		case quote == 0 && (text[i] == '"' || text[i] == '\''):
+			quote = text[i]
+		case quote == 0 && text[i] == ',':
+			operands = append(operands, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(operands, strings.TrimSpace(text[start:]))
+}
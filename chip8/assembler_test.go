@@ -0,0 +1,142 @@
+package chip8
+
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+
+// assembleFiles writes files into a temporary directory and assembles the
+// first one, error messages name the files without the directory
+func assembleFiles(t *testing.T, variant Variant, files ...[2]string) ([]byte, []string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, file[0]), []byte(file[1]), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rom, _, err := Assemble(filepath.Join(dir, files[0][0]), variant)
+	if err == nil {
+		return rom, nil
+	}
+
+	errs, ok := err.(AssemblyErrors)
+	if !ok {
+		t.Fatalf("Got %T, expected AssemblyErrors: %v", err, err)
+	}
+
+	messages := []string{}
+	for _, assemblyErr := range errs {
+		messages = append(messages, strings.Replace(assemblyErr.Error(), dir + string(filepath.Separator), "", -1))
+	}
+	return nil, messages
+}
+
+
+func TestAssemble(t *testing.T) {
+	source := `SPEED = 3
+WIDTH equ SPEED * 2 + 1   ; constants can use others
+main:
+	LD V0, SPEED
+	LD V1, (WIDTH << 1) | 0x80
+	LD I, sprite
+loop: JMP loop
+	org 0x210
+sprite:
+	db 0b11110000, 'A', "hi"
+	dw main, 0x1234
+`
+	rom, errs := assembleFiles(t, VARIANT_CHIP8, [2]string{"main.asm", source})
+	if errs != nil {
+		t.Fatalf("Errors assembling:\n%s", strings.Join(errs, "\n"))
+	}
+
+	expected := []byte{
+		0x60, 0x03, 0x61, 0x8E, 0xA2, 0x10, 0x12, 0x06,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xF0, 0x41, 0x68, 0x69, 0x02, 0x00, 0x12, 0x34,
+	}
+	if !bytes.Equal(rom, expected) {
+		t.Errorf("Got % X, expected % X", rom, expected)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		variant Variant
+		files [][2]string
+		errors []string
+	}{
+		{"unknown instruction", VARIANT_CHIP8, [][2]string{{"main.asm", "CLS\nFROB V0\n"}}, []string{
+			"main.asm:2: Unknown instruction FROB",
+		}},
+		{"operands", VARIANT_CHIP8, [][2]string{{"main.asm", "LD V0\nJMP 0x1000\nLD V0, 256\n"}}, []string{
+			"main.asm:1: Invalid operands for LD",
+			"main.asm:2: 0x1000 is out of range, expected 0 to 0xFFF",
+			"main.asm:3: 256 is out of range, expected -128 to 0xFF",
+		}},
+		{"variant", VARIANT_CHIP8, [][2]string{{"main.asm", "HIGH\n"}}, []string{
+			"main.asm:1: HIGH  needs the schip variant",
+		}},
+		{"labels", VARIANT_CHIP8, [][2]string{{"main.asm", "a: CLS\na: CLS\nJMP later\nV0: CLS\n"}}, []string{
+			"main.asm:2: a is already defined",
+			"main.asm:3: Undefined name later",
+			"main.asm:4: V0 is reserved and can't be a label",
+		}},
+		{"expressions", VARIANT_CHIP8, [][2]string{{"main.asm", "ONE = TWO\nTWO = ONE\nLD V0, ONE\nLD V1, 1 / 0\nLD V2, (1 + 2\n"}}, []string{
+			"main.asm:3: ONE is defined in terms of itself",
+			"main.asm:4: Division by zero in \"1 / 0\"",
+			"main.asm:5: Missing ) in \"(1 + 2\"",
+		}},
+		{"org", VARIANT_CHIP8, [][2]string{{"main.asm", "org 0x300\nCLS\norg 0x200\n"}}, []string{
+			"main.asm:3: org 0x200 is before the current address 0x302",
+		}},
+		{"too large", VARIANT_CHIP8, [][2]string{{"main.asm", "org 0xFFF\ndw 0\n"}}, []string{
+			"main.asm:2: The program does not fit in 4096 bytes of memory",
+		}},
+		{"includes", VARIANT_CHIP8, [][2]string{
+			{"main.asm", "CLS\ninclude \"other.asm\"\nFROB\ninclude \"missing.asm\"\n"},
+			{"other.asm", "RET\nLD V0\n"},
+		}, []string{
+			"main.asm:3: Unknown instruction FROB",
+			"main.asm:4: open missing.asm",
+			"other.asm:2: Invalid operands for LD",
+		}},
+		{"include cycle", VARIANT_CHIP8, [][2]string{
+			{"main.asm", "include \"other.asm\"\n"},
+			{"other.asm", "include \"main.asm\"\n"},
+		}, []string{
+			"other.asm:1: main.asm includes itself",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, errs := assembleFiles(t, test.variant, test.files...)
+			if len(errs) != len(test.errors) {
+				t.Fatalf("Got errors\n%s\nexpected\n%s", strings.Join(errs, "\n"), strings.Join(test.errors, "\n"))
+			}
+
+			for i, err := range errs {
+				if !strings.HasPrefix(err, test.errors[i]) {
+					t.Errorf("Got %q, expected it to start with %q", err, test.errors[i])
+				}
+			}
+		})
+	}
+}
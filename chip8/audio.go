@@ -0,0 +1,181 @@
+package chip8
+
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+
+const (
+	// samples per second of the generated PCM
+	AUDIO_SAMPLE_RATE = 44100
+	// pitch of the tone in Hz
+	TONE_FREQUENCY = 440
+	// 8 bit unsigned samples, silence is the middle
+	SAMPLE_SILENCE = 0x80
+	SAMPLE_AMPLITUDE = 0x40
+)
+
+
+// Audio is a frontend that plays the tone of the sound timer
+type Audio interface {
+	// Tick is called by Step 60 times a second when the timers are
+	// decremented, with whether the tone sounds until the next tick
+	Tick(sounding bool)
+}
+
+
+// nullAudio plays nothing
+type nullAudio struct{}
+
+
+func (nullAudio) Tick(sounding bool) {
+}
+
+
+// PCMAudio generates the tone as a square wave of 8 bit unsigned mono samples
+// at AUDIO_SAMPLE_RATE, one tick worth of samples per tick, with silence
+// while the tone is off
+type PCMAudio struct {
+	out io.Writer
+	// samples generated so far, which keeps the wave in phase across ticks
+	samples int
+	// the first error writing samples
+	err error
+}
+
+
+func NewPCMAudio(out io.Writer) *PCMAudio {
+	audio := new(PCMAudio)
+	audio.out = out
+
+	return audio
+}
+
+
+func (audio *PCMAudio) Tick(sounding bool) {
+	if audio.err != nil {
+		return
+	}
+
+	// spread the samples of a second evenly over 60 ticks
+	tick := audio.samples * 60 / AUDIO_SAMPLE_RATE
+	count := (tick + 1) * AUDIO_SAMPLE_RATE / 60 - audio.samples
+
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = SAMPLE_SILENCE
+		if !sounding {
+			continue
+		}
+
+		// two half periods per period
+		half := (audio.samples + i) * TONE_FREQUENCY * 2 / AUDIO_SAMPLE_RATE
+		if half % 2 == 0 {
+			data[i] += SAMPLE_AMPLITUDE
+		} else {
+			data[i] -= SAMPLE_AMPLITUDE
+		}
+	}
+
+	_, audio.err = audio.out.Write(data)
+	audio.samples += count
+}
+
+// Samples is the number of samples generated so far
+func (audio *PCMAudio) Samples() int {
+	return audio.samples
+}
+
+// Err is the first error writing samples
+func (audio *PCMAudio) Err() error {
+	return audio.err
+}
+
+
+// WAVAudio records the tone into a WAV file, which is only complete once
+// closed
+type WAVAudio struct {
+	*PCMAudio
+
+	out io.WriteSeeker
+}
+
+
+// NewWAVAudio writes the header of a WAV file to out, the sizes in it are
+// filled in by Close
+func NewWAVAudio(out io.WriteSeeker) (*WAVAudio, error) {
+	audio := new(WAVAudio)
+	audio.PCMAudio = NewPCMAudio(out)
+	audio.out = out
+
+	err := audio.writeHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	return audio, nil
+}
+
+
+func (audio *WAVAudio) writeHeader() error {
+	size := uint32(audio.samples)
+
+	header := []interface{}{
+		[]byte("RIFF"),
+		// including the pad byte of an odd sized data chunk
+		36 + size + size % 2,
+		[]byte("WAVE"),
+		[]byte("fmt "),
+		// size of the format chunk, PCM, channels
+		uint32(16),
+		uint16(1),
+		uint16(1),
+		uint32(AUDIO_SAMPLE_RATE),
+		// bytes per second, bytes per sample, bits per sample
+		uint32(AUDIO_SAMPLE_RATE),
+		uint16(1),
+		uint16(8),
+		[]byte("data"),
+		size,
+	}
+
+	for _, field := range header {
+		err := binary.Write(audio.out, binary.LittleEndian, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close fills in the sizes in the header and reports the first error
+// writing the file, the underlying file is left open
+func (audio *WAVAudio) Close() error {
+	if audio.err != nil {
+		return audio.err
+	}
+
+	// chunks have an even size, an odd one is followed by a pad byte
+	if audio.samples % 2 != 0 {
+		_, err := audio.out.Write([]byte{0})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := audio.out.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = audio.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	_, err = audio.out.Seek(0, io.SeekEnd)
+	return err
+}
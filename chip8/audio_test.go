@@ -0,0 +1,98 @@
+package chip8
+
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+
+func TestWAVAudioFollowsTheSoundTimer(t *testing.T) {
+	file, err := ioutil.TempFile("", "audio*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	audio, err := NewWAVAudio(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// V0 = 30, ST = V0, then loop, with the timers ticking every instruction
+	rom := []byte{0x60, 0x1E, 0xF0, 0x18, 0x12, 0x04}
+	sys := newTestSystem(t, rom, WithAudio(audio), WithClockspeed(60), WithVirtualClock(true))
+	err = sys.RunCycles(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = audio.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a tick is 1/60th of a second, and the tone sounds for 30 of them
+	samples := data[44:]
+	if len(samples) != AUDIO_SAMPLE_RATE {
+		t.Errorf("Got %d samples, expected %d", len(samples), AUDIO_SAMPLE_RATE)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); int(size) != len(samples) {
+		t.Errorf("Header has %d samples, the file %d", size, len(samples))
+	}
+
+	sounding := 0
+	for _, sample := range samples {
+		if sample != SAMPLE_SILENCE {
+			sounding++
+		}
+	}
+	if sounding != AUDIO_SAMPLE_RATE / 2 {
+		t.Errorf("Tone sounds for %d samples, expected %d", sounding, AUDIO_SAMPLE_RATE / 2)
+	}
+}
+
+func TestWAVAudioPadsOddData(t *testing.T) {
+	file, err := ioutil.TempFile("", "audio*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	audio, err := NewWAVAudio(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a tick has an odd number of samples
+	audio.Tick(true)
+	err = audio.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := AUDIO_SAMPLE_RATE / 60
+	if len(data) != 44 + samples + 1 || data[len(data) - 1] != 0 {
+		t.Fatalf("File is %d bytes ending in 0x%02X, expected %d ending in a pad byte", len(data), data[len(data) - 1], 44 + samples + 1)
+	}
+	if size := binary.LittleEndian.Uint32(data[4:]); int(size) != len(data) - 8 {
+		t.Errorf("RIFF size is %d, expected %d", size, len(data) - 8)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); int(size) != samples {
+		t.Errorf("Data size is %d, expected %d", size, samples)
+	}
+}
@@ -0,0 +1,197 @@
+package chip8
+
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+
+// BasicBlock is a run of instructions that always execute one after the
+// other, it ends at a jump, call, return or skip
+type BasicBlock struct {
+	Start int
+	// addresses of the instructions in order
+	Addresses []int
+	// starts of the blocks execution continues with
+	Successors []int
+}
+
+// Subroutine is the blocks reachable from an entry point without following
+// calls. The program itself is the subroutine at PC_START.
+type Subroutine struct {
+	Entry int
+	Name string
+	Blocks []*BasicBlock
+}
+
+
+// endsBlock reports whether execution may not continue with the next
+// instruction after inst
+func endsBlock(inst Instruction) bool {
+	switch inst.Op {
+	case OP_JMP, OP_CALL, OP_RET, OP_JMP_V0, OP_EXIT,
+		OP_SE_BYTE, OP_SNE_BYTE, OP_SE_REG, OP_SNE_REG, OP_SKP, OP_SKNP:
+		return true
+	}
+
+	return inst.Halts()
+}
+
+// Blocks splits the reached instructions into basic blocks by start address
+func (flow *Flow) Blocks() map[int]*BasicBlock {
+	leaders := map[int]bool{PC_START: true}
+	for address := range flow.Subroutines {
+		leaders[address] = true
+	}
+	for address, inst := range flow.Instructions {
+		if !endsBlock(inst) {
+			continue
+		}
+		for _, successor := range flow.Successors[address] {
+			leaders[successor] = true
+		}
+	}
+
+	blocks := make(map[int]*BasicBlock)
+	for start := range leaders {
+		if _, ok := flow.Instructions[start]; !ok {
+			continue
+		}
+
+		block := &BasicBlock{Start: start}
+		address := start
+		for {
+			inst := flow.Instructions[address]
+			block.Addresses = append(block.Addresses, address)
+
+			next := address + int(inst.Size())
+			_, reached := flow.Instructions[next]
+			if endsBlock(inst) || leaders[next] || !reached {
+				break
+			}
+			address = next
+		}
+
+		for _, successor := range flow.Successors[address] {
+			if _, ok := flow.Instructions[successor]; ok {
+				block.Successors = append(block.Successors, successor)
+			}
+		}
+		sort.Ints(block.Successors)
+
+		blocks[start] = block
+	}
+
+	return blocks
+}
+
+// Routines groups the basic blocks by the subroutines they belong to, a
+// block shared by several subroutines is part of each
+func (flow *Flow) Routines() []Subroutine {
+	blocks := flow.Blocks()
+
+	entries := []int{PC_START}
+	for address := range flow.Subroutines {
+		if address != PC_START {
+			entries = append(entries, address)
+		}
+	}
+	sort.Ints(entries[1:])
+
+	routines := []Subroutine{}
+	for _, entry := range entries {
+		if blocks[entry] == nil {
+			continue
+		}
+
+		routine := Subroutine{Entry: entry, Name: routineName(entry)}
+		seen := map[int]bool{entry: true}
+		pending := []int{entry}
+		for len(pending) > 0 {
+			block := blocks[pending[0]]
+			pending = pending[1:]
+			routine.Blocks = append(routine.Blocks, block)
+
+			for _, successor := range block.Successors {
+				if !seen[successor] {
+					seen[successor] = true
+					pending = append(pending, successor)
+				}
+			}
+		}
+		sort.Slice(routine.Blocks, func(i int, j int) bool {
+			return routine.Blocks[i].Start < routine.Blocks[j].Start
+		})
+
+		routines = append(routines, routine)
+	}
+
+	return routines
+}
+
+func routineName(entry int) string {
+	if entry == PC_START {
+		return "main"
+	}
+
+	return fmt.Sprintf("sub_%03X", entry)
+}
+
+// WriteDOT writes a Graphviz digraph for every subroutine, with a node per
+// basic block listing what its instructions do with quirks
+func (flow *Flow) WriteDOT(out io.Writer, quirks Quirks) error {
+	writer := bufio.NewWriter(out)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	for _, routine := range flow.Routines() {
+		fmt.Fprintf(writer, "digraph %s {\n", routine.Name)
+		fmt.Fprintf(writer, "\tlabel=\"%s at 0x%03X\";\n", routine.Name, routine.Entry)
+		fmt.Fprintln(writer, "\tnode [shape=box, fontname=monospace];")
+
+		for _, block := range routine.Blocks {
+			lines := ""
+			for _, address := range block.Addresses {
+				inst := flow.Instructions[address]
+				lines += escaper.Replace(fmt.Sprintf("0x%03X: %s", address, DescribeOp(inst, flow.variant, quirks))) + `\l`
+			}
+			fmt.Fprintf(writer, "\tblock_%03X [label=\"%s\"];\n", block.Start, lines)
+		}
+
+		for _, block := range routine.Blocks {
+			last := block.Addresses[len(block.Addresses) - 1]
+			inst := flow.Instructions[last]
+			next := last + int(inst.Size())
+
+			for _, successor := range block.Successors {
+				label := ""
+				switch {
+				case inst.Op == OP_CALL:
+					label = "after " + routineName(int(inst.Address))
+					break
+
+				case inst.Op == OP_JMP_V0:
+					label = "V0"
+					break
+
+				case endsBlock(inst) && inst.Op != OP_JMP && successor != next:
+					label = "skip"
+					break
+				}
+
+				if label == "" {
+					fmt.Fprintf(writer, "\tblock_%03X -> block_%03X;\n", block.Start, successor)
+				} else {
+					fmt.Fprintf(writer, "\tblock_%03X -> block_%03X [label=\"%s\"];\n", block.Start, successor, label)
+				}
+			}
+		}
+
+		fmt.Fprintln(writer, "}")
+	}
+
+	return writer.Flush()
+}
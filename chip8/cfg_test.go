@@ -0,0 +1,44 @@
+package chip8
+
+
+import (
+	"bytes"
+	"testing"
+)
+
+
+func TestWriteDOT(t *testing.T) {
+	// SE V0, 1 skipping a call, then a loop, and the subroutine
+	rom := []byte{
+		0x30, 0x01, 0x22, 0x08, 0x70, 0x01, 0x12, 0x00,
+		0x81, 0x21, 0x00, 0xEE,
+	}
+
+	var out bytes.Buffer
+	err := FollowFlow(rom, VARIANT_CHIP8).WriteDOT(&out, QUIRKS_VIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the description follows the quirks, OR clears VF here
+	expected := `digraph main {
+	label="main at 0x200";
+	node [shape=box, fontname=monospace];
+	block_200 [label="0x200: [SE] - Skip next instruction if registers[0x0] == 0x1\l"];
+	block_202 [label="0x202: [CALL] - Call subroutine at 0x208\l"];
+	block_204 [label="0x204: [ADD] - registers[0x0] += 0x1\l0x206: [JMP] - Jump to 0x200\l"];
+	block_200 -> block_202;
+	block_200 -> block_204 [label="skip"];
+	block_202 -> block_204 [label="after sub_208"];
+	block_204 -> block_200;
+}
+digraph sub_208 {
+	label="sub_208 at 0x208";
+	node [shape=box, fontname=monospace];
+	block_208 [label="0x208: [OR] - registers[0x1] |= registers[0x2] and clear registers[0xF]\l0x20A: [RET] - Return from subroutine\l"];
+}
+`
+	if out.String() != expected {
+		t.Errorf("Got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
@@ -0,0 +1,310 @@
+package chip8
+
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+
+// Registers is a snapshot of the CPU state as shown by debuggers
+type Registers struct {
+	V [REGISTER_COUNT]byte
+	I uint16
+	PC uint16
+	// number of return addresses on the stack
+	SP uint
+	DelayTimer byte
+	SoundTimer byte
+}
+
+
+// String lists the registers on one line, in hexadecimal
+func (registers Registers) String() string {
+	fields := []string{
+		fmt.Sprintf("PC=%04X", registers.PC),
+		fmt.Sprintf("I=%04X", registers.I),
+		fmt.Sprintf("SP=%X", registers.SP),
+		fmt.Sprintf("DT=%02X", registers.DelayTimer),
+		fmt.Sprintf("ST=%02X", registers.SoundTimer),
+	}
+	for i, value := range registers.V {
+		fields = append(fields, fmt.Sprintf("V%X=%02X", i, value))
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// Registers returns the current CPU state
+func (sys *System) Registers() Registers {
+	var registers Registers
+
+	copy(registers.V[:], sys.registers)
+	registers.I = sys.iregister
+	registers.PC = sys.programCounter
+	registers.SP = sys.stack.index
+	registers.DelayTimer = sys.delayTimer
+	registers.SoundTimer = sys.soundTimer
+
+	return registers
+}
+
+// SetRegisters replaces the CPU state, such as when a debugger edits it
+func (sys *System) SetRegisters(registers Registers) error {
+	if registers.SP > sys.stack.capacity {
+		return fmt.Errorf("Stack pointer %d is out of range", registers.SP)
+	}
+
+	copy(sys.registers, registers.V[:])
+	sys.iregister = registers.I
+	sys.programCounter = registers.PC
+	sys.stack.index = registers.SP
+	sys.delayTimer = registers.DelayTimer
+	sys.soundTimer = registers.SoundTimer
+
+	return nil
+}
+
+// Stack describes the call stack, as formatted by Stack.String
+func (sys *System) Stack() string {
+	return sys.stack.String()
+}
+
+// CallStack lists the addresses of the calls which have not returned yet, the
+// innermost one last
+func (sys *System) CallStack() []uint16 {
+	calls := make([]uint16, sys.stack.index)
+	copy(calls, sys.stack.memory)
+
+	return calls
+}
+
+// ReadMemory copies length bytes starting at address. Bytes outside of memory
+// read as 0 and a length of 0 or less reads nothing.
+func (sys *System) ReadMemory(address int, length int) []byte {
+	if length <= 0 {
+		return nil
+	}
+
+	data := make([]byte, length)
+	for i := range data {
+		if address + i >= 0 && address + i < len(sys.memory) {
+			data[i] = sys.memory[address + i]
+		}
+	}
+
+	return data
+}
+
+// WriteMemory stores data starting at address, as long as it fits in memory
+func (sys *System) WriteMemory(address int, data []byte) error {
+	if address < 0 || address + len(data) > len(sys.memory) {
+		return fmt.Errorf("Memory range 0x%X-0x%X is out of range", address, address + len(data))
+	}
+
+	copy(sys.memory[address:], data)
+
+	return nil
+}
+
+// MemorySize is the number of bytes of memory of the variant
+func (sys *System) MemorySize() int {
+	return len(sys.memory)
+}
+
+// InstructionAt decodes the instruction stored at address
+func (sys *System) InstructionAt(address int) Instruction {
+	return DecodeAt(sys.memory, address)
+}
+
+
+// Debugger controls the execution of a System for interactive debugging. It
+// starts out paused, and while it is running every instruction goes through
+// Tick so it can stop at breakpoints.
+type Debugger struct {
+	sys *System
+
+	breakpoints map[uint16]bool
+
+	paused bool
+	// why the debugger last paused
+	reason string
+
+	// set while stepping over a call, where it returns to and at which depth
+	stepping bool
+	returnAddress uint16
+	returnDepth uint
+
+	watchpoints []Watchpoint
+	// ID of the last watchpoint added
+	nextWatch int
+	// the watchpoint hit which last paused the debugger
+	hit *WatchHit
+}
+
+
+func NewDebugger(sys *System) *Debugger {
+	dbg := new(Debugger)
+	dbg.sys = sys
+	dbg.breakpoints = make(map[uint16]bool)
+	dbg.paused = true
+	dbg.reason = "Paused"
+
+	// keep a hook that was already installed, such as another debugger's
+	previous := sys.onWrite
+	sys.onWrite = func(address uint16, old byte, value byte) {
+		if previous != nil {
+			previous(address, old, value)
+		}
+		dbg.recordWrite(address, old, value)
+	}
+
+	return dbg
+}
+
+
+// System is the system being debugged
+func (dbg *Debugger) System() *System {
+	return dbg.sys
+}
+
+// SetBreakpoint pauses execution before the instruction at address runs
+func (dbg *Debugger) SetBreakpoint(address uint16) {
+	dbg.breakpoints[address] = true
+}
+
+func (dbg *Debugger) ClearBreakpoint(address uint16) {
+	delete(dbg.breakpoints, address)
+}
+
+func (dbg *Debugger) HasBreakpoint(address uint16) bool {
+	return dbg.breakpoints[address]
+}
+
+// Breakpoints lists the breakpoint addresses in ascending order
+func (dbg *Debugger) Breakpoints() []uint16 {
+	addresses := make([]uint16, 0, len(dbg.breakpoints))
+	for address := range dbg.breakpoints {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i int, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+
+	return addresses
+}
+
+func (dbg *Debugger) Paused() bool {
+	return dbg.paused
+}
+
+// Reason describes why the debugger last paused
+func (dbg *Debugger) Reason() string {
+	return dbg.reason
+}
+
+func (dbg *Debugger) Pause(reason string) {
+	dbg.paused = true
+	dbg.stepping = false
+	dbg.reason = reason
+}
+
+// Continue resumes execution until a breakpoint is hit
+func (dbg *Debugger) Continue() {
+	dbg.paused = false
+	dbg.stepping = false
+}
+
+// StepInto executes a single instruction and stays paused
+func (dbg *Debugger) StepInto() (StepResult, error) {
+	dbg.Continue()
+
+	result, err := dbg.step()
+	if !dbg.paused {
+		dbg.Pause("Stepped")
+	}
+
+	return result, err
+}
+
+// StepOver executes a single instruction, or a whole subroutine if the next
+// instruction is a call. Subroutines run through Tick and stop early at
+// breakpoints.
+func (dbg *Debugger) StepOver() (StepResult, error) {
+	inst := dbg.sys.InstructionAt(int(dbg.sys.programCounter))
+	if inst.Op != OP_CALL {
+		return dbg.StepInto()
+	}
+
+	dbg.Continue()
+	dbg.stepping = true
+	dbg.returnAddress = dbg.sys.programCounter + uint16(inst.Size())
+	dbg.returnDepth = dbg.sys.stack.index
+
+	return dbg.step()
+}
+
+// StepOut runs until the current subroutine returns, or executes a single
+// instruction outside of subroutines
+func (dbg *Debugger) StepOut() (StepResult, error) {
+	call, err := dbg.sys.stack.peek()
+	if err != nil {
+		return dbg.StepInto()
+	}
+
+	dbg.Continue()
+	dbg.stepping = true
+	dbg.returnAddress = call + dbg.sys.InstructionAt(int(call)).Size()
+	dbg.returnDepth = dbg.sys.stack.index - 1
+
+	return dbg.step()
+}
+
+// Tick executes the next instruction unless the debugger is paused, then
+// pauses if a breakpoint or the end of a stepped over subroutine is reached
+func (dbg *Debugger) Tick() (StepResult, error) {
+	if dbg.paused {
+		pc := dbg.sys.programCounter
+		return StepResult{PCBefore: pc, PCAfter: pc}, nil
+	}
+
+	return dbg.step()
+}
+
+func (dbg *Debugger) step() (StepResult, error) {
+	dbg.hit = nil
+	dbg.refreshWatchpoints()
+
+	result, err := dbg.sys.Step()
+	if err != nil {
+		// a write recorded before the instruction failed did not pause it
+		dbg.hit = nil
+		dbg.Pause(fmt.Sprintf("Error: %v", err))
+		return result, err
+	}
+
+	hit := dbg.checkWatchpoints(result)
+
+	pc := dbg.sys.programCounter
+	switch {
+	case hit != nil:
+		dbg.Pause(hit.String())
+		dbg.hit = hit
+		break
+
+	case result.Halted:
+		dbg.Pause("Program exited")
+		break
+
+	case dbg.stepping && pc == dbg.returnAddress && dbg.sys.stack.index == dbg.returnDepth:
+		dbg.Pause("Stepped")
+		break
+
+	case dbg.breakpoints[pc]:
+		dbg.Pause(fmt.Sprintf("Breakpoint at 0x%03X", pc))
+		break
+	}
+
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package chip8
+
+
+import (
+	"reflect"
+	"testing"
+)
+
+
+// a call of a subroutine which calls another, then a loop
+var DEBUGGER_TEST_ROM = []byte{
+	0x60, 0x01, 0x22, 0x08, 0x61, 0x02, 0x12, 0x06,
+	0x62, 0x03, 0x22, 0x0E, 0x00, 0xEE, 0x63, 0x04,
+	0x00, 0xEE,
+}
+
+
+// runUntilPaused ticks the debugger until it pauses
+func runUntilPaused(t *testing.T, dbg *Debugger) {
+	t.Helper()
+
+	for i := 0; i < 100 && !dbg.Paused(); i++ {
+		_, err := dbg.Tick()
+		if err != nil {
+			t.Fatalf("Error running: %v", err)
+		}
+	}
+	if !dbg.Paused() {
+		t.Fatalf("Did not pause")
+	}
+}
+
+func expectPC(t *testing.T, dbg *Debugger, pc uint16) {
+	t.Helper()
+
+	if dbg.System().Registers().PC != pc {
+		t.Fatalf("Paused at 0x%03X (%s), expected 0x%03X", dbg.System().Registers().PC, dbg.Reason(), pc)
+	}
+}
+
+
+func TestDebuggerStartsPaused(t *testing.T) {
+	dbg := NewDebugger(newTestSystem(t, DEBUGGER_TEST_ROM))
+	for i := 0; i < 10; i++ {
+		dbg.Tick()
+	}
+
+	expectPC(t, dbg, 0x200)
+	if !dbg.Paused() || dbg.Reason() != "Paused" {
+		t.Errorf("Paused is %v because of %q", dbg.Paused(), dbg.Reason())
+	}
+}
+
+func TestDebuggerSteps(t *testing.T) {
+	dbg := NewDebugger(newTestSystem(t, DEBUGGER_TEST_ROM))
+
+	dbg.StepInto()
+	expectPC(t, dbg, 0x202)
+	if !dbg.Paused() || dbg.Reason() != "Stepped" {
+		t.Errorf("Paused is %v because of %q after stepping", dbg.Paused(), dbg.Reason())
+	}
+
+	// over both calls at once
+	dbg.StepOver()
+	runUntilPaused(t, dbg)
+	expectPC(t, dbg, 0x204)
+	if registers := dbg.System().Registers(); registers.V[2] != 3 || registers.V[3] != 4 {
+		t.Errorf("The subroutines did not run: %s", registers)
+	}
+
+	// stepping over anything else steps into it
+	dbg.StepOver()
+	expectPC(t, dbg, 0x206)
+}
+
+func TestDebuggerStepsOut(t *testing.T) {
+	dbg := NewDebugger(newTestSystem(t, DEBUGGER_TEST_ROM))
+	dbg.StepInto()
+	dbg.StepInto()
+	dbg.StepInto()
+	expectPC(t, dbg, 0x20A)
+
+	dbg.StepOut()
+	runUntilPaused(t, dbg)
+	expectPC(t, dbg, 0x204)
+
+	// outside of subroutines it steps
+	dbg.StepOut()
+	expectPC(t, dbg, 0x206)
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	dbg := NewDebugger(newTestSystem(t, DEBUGGER_TEST_ROM))
+	dbg.SetBreakpoint(0x20E)
+	dbg.SetBreakpoint(0x204)
+	dbg.SetBreakpoint(0x300)
+	dbg.ClearBreakpoint(0x300)
+
+	if breakpoints := dbg.Breakpoints(); !reflect.DeepEqual(breakpoints, []uint16{0x204, 0x20E}) {
+		t.Errorf("Breakpoints are %v, expected 0x204 and 0x20E", breakpoints)
+	}
+
+	dbg.Continue()
+	runUntilPaused(t, dbg)
+	expectPC(t, dbg, 0x20E)
+	if dbg.Reason() != "Breakpoint at 0x20E" {
+		t.Errorf("Paused because of %q", dbg.Reason())
+	}
+
+	// stepping out stops at a breakpoint on the way too
+	dbg.ClearBreakpoint(0x204)
+	dbg.SetBreakpoint(0x20C)
+	dbg.StepOut()
+	runUntilPaused(t, dbg)
+	expectPC(t, dbg, 0x20C)
+
+	dbg.Continue()
+	for i := 0; i < 10; i++ {
+		dbg.Tick()
+	}
+	if dbg.Paused() {
+		t.Errorf("Paused at 0x%03X after the breakpoints: %s", dbg.System().Registers().PC, dbg.Reason())
+	}
+}
+
+func TestDebuggerPausesAtExit(t *testing.T) {
+	dbg := NewDebugger(newTestSystem(t, []byte{0x60, 0x01, 0x00, 0xFD}, WithVariant(VARIANT_SCHIP)))
+	dbg.Continue()
+	runUntilPaused(t, dbg)
+
+	if dbg.Reason() != "Program exited" {
+		t.Errorf("Paused because of %q", dbg.Reason())
+	}
+}
@@ -0,0 +1,311 @@
+package chip8
+
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+
+// Disassemble writes a description of every instruction of the loaded ROM
+// reached from its start, the data it reads and draws and the regions that
+// are neither
+func (sys *System) Disassemble(out io.Writer) {
+	rom := sys.ROM()
+	flow := FollowFlow(rom, sys.variant)
+
+	for offset := 0; offset < len(rom); offset++ {
+		i := PC_START + offset
+
+		switch flow.Kinds[offset] {
+		case BYTE_CODE:
+			inst := flow.Instructions[i]
+			if inst.Size() == 4 {
+				fmt.Fprintf(out, "0x%04X: 0x%04X %04X %s\n", i, inst.Opcode, inst.Long, DescribeOp(inst, sys.variant, sys.quirks))
+			} else {
+				fmt.Fprintf(out, "0x%04X: 0x%04X %s\n", i, inst.Opcode, DescribeOp(inst, sys.variant, sys.quirks))
+			}
+			break
+
+		case BYTE_SPRITE:
+			row := strings.NewReplacer("0", ".", "1", "#").Replace(fmt.Sprintf("%08b", rom[offset]))
+			fmt.Fprintf(out, "0x%04X: 0x%02X [SPRITE] %s\n", i, rom[offset], row)
+			break
+
+		case BYTE_DATA:
+			fmt.Fprintf(out, "0x%04X: 0x%02X [DATA]\n", i, rom[offset])
+			break
+
+		case BYTE_UNREACHABLE:
+			start := offset
+			for offset + 1 < len(rom) && flow.Kinds[offset + 1] == BYTE_UNREACHABLE {
+				offset++
+			}
+			fmt.Fprintf(out, "0x%04X: [UNREACHABLE] %d bytes up to 0x%04X\n", i, offset - start + 1, PC_START + offset)
+			break
+		}
+	}
+}
+
+
+// DescribeOp explains what a decoded instruction does in prose, as it runs on
+// variant with quirks
+func DescribeOp(inst Instruction, variant Variant, quirks Quirks) string {
+	if inst.Valid() && inst.Variant() > variant {
+		return fmt.Sprintf("[N/A] - %s instruction, not available on %s", inst.Variant(), variant)
+	}
+
+	x := inst.X
+	y := inst.Y
+	n := inst.N
+	nnn := inst.Address
+	kk := inst.K
+
+	switch inst.Op {
+	// CLS - Clear display
+	case OP_CLS:
+		return "[CLS] - Clear display"
+
+	// RET - return from subroutine
+	case OP_RET:
+		return "[RET] - Return from subroutine"
+
+	// SYS - jump to machine code routine at address
+	case OP_SYS:
+		if inst.Halts() {
+			return "[SYS] - Exit"
+		}
+		return fmt.Sprintf("[SYS] - Call machine code routine at 0x%X (not supported)", nnn)
+
+	// SCD - scroll display down
+	case OP_SCD:
+		return fmt.Sprintf("[SCD] - Scroll display down %d lines", n)
+
+	// SCR - scroll display right
+	case OP_SCR:
+		return "[SCR] - Scroll display right 4 pixels"
+
+	// SCL - scroll display left
+	case OP_SCL:
+		return "[SCL] - Scroll display left 4 pixels"
+
+	// SCU - scroll display up
+	case OP_SCU:
+		return fmt.Sprintf("[SCU] - Scroll display up %d lines", n)
+
+	// EXIT - exit the interpreter
+	case OP_EXIT:
+		return "[EXIT] - Exit"
+
+	// LOW - low resolution
+	case OP_LOW:
+		return "[LOW] - Switch to 64x32 resolution"
+
+	// HIGH - high resolution
+	case OP_HIGH:
+		return "[HIGH] - Switch to 128x64 resolution"
+
+	// JMP - jump to address
+	case OP_JMP:
+		return fmt.Sprintf("[JMP] - Jump to 0x%X", nnn)
+
+	// CALL - call subroutine
+	case OP_CALL:
+		return fmt.Sprintf("[CALL] - Call subroutine at 0x%X", nnn)
+
+	// SE - Skip next instruction if Vx == val
+	case OP_SE_BYTE:
+		return fmt.Sprintf("[SE] - Skip next instruction if registers[0x%X] == 0x%X", x, kk)
+
+	// SNE - skip next instruction if Vx != val
+	case OP_SNE_BYTE:
+		return fmt.Sprintf("[SNE] - Skip next instruction if registers[0x%X] != 0x%X", x, kk)
+
+	// SE - skip if Vx == Vy
+	case OP_SE_REG:
+		return fmt.Sprintf("[SE] - Skip next instruction if registers[0x%X] == registers[0x%X]", x, y)
+
+	// SAVE - store a range of registers
+	case OP_SAVE:
+		return fmt.Sprintf("[SAVE] - Store registers[0x%X] to registers[0x%X] into memory[I] onwards", x, y)
+
+	// LOAD - load a range of registers
+	case OP_LOAD_RANGE:
+		return fmt.Sprintf("[LOAD] - Load registers[0x%X] to registers[0x%X] from memory[I] onwards", x, y)
+
+	// LD - sets register
+	case OP_LD_BYTE:
+		return fmt.Sprintf("[LD] - Set registers[0x%X] to 0x%X", x, kk)
+
+	// ADD - Vx = Vx + val
+	case OP_ADD_BYTE:
+		return fmt.Sprintf("[ADD] - registers[0x%X] += 0x%X", x, kk)
+
+	// LD - set register
+	case OP_LD_REG:
+		return fmt.Sprintf("[LD] - registers[0x%X] = registers[0x%X]", x, y)
+
+	// OR
+	case OP_OR:
+		if quirks.VFReset {
+			return fmt.Sprintf("[OR] - registers[0x%X] |= registers[0x%X] and clear registers[0xF]", x, y)
+		}
+		return fmt.Sprintf("[OR] - registers[0x%X] |= registers[0x%X]", x, y)
+
+	// AND
+	case OP_AND:
+		if quirks.VFReset {
+			return fmt.Sprintf("[AND] - registers[0x%X] &= registers[0x%X] and clear registers[0xF]", x, y)
+		}
+		return fmt.Sprintf("[AND] - registers[0x%X] &= registers[0x%X]", x, y)
+
+	// XOR
+	case OP_XOR:
+		if quirks.VFReset {
+			return fmt.Sprintf("[XOR] - registers[0x%X] ^= registers[0x%X] and clear registers[0xF]", x, y)
+		}
+		return fmt.Sprintf("[XOR] - registers[0x%X] ^= registers[0x%X]", x, y)
+
+	// ADD
+	case OP_ADD_REG:
+		return fmt.Sprintf("[ADD] - registers[0x%X] += registers[0x%X] and set registers[0xF] for overflow", x, y)
+
+	// SUB
+	case OP_SUB:
+		return fmt.Sprintf("[SUB] - registers[0x%X] -= registers[0x%X] and set registers[0xF] for borrow", x, y)
+
+	// SHR
+	case OP_SHR:
+		if quirks.Shift {
+			return fmt.Sprintf("[SHR] - registers[0x%X] /= 2 and set registers[0xF] to the low bit", x)
+		}
+		return fmt.Sprintf("[SHR] - registers[0x%X] = registers[0x%X] / 2 and set registers[0xF] to the low bit of registers[0x%X]", x, y, y)
+
+	// SUBN
+	case OP_SUBN:
+		return fmt.Sprintf("[SUBN] - registers[0x%X] = registers[0x%X] - registers[0x%X] and set registers[0xF] for borrow", x, y, x)
+
+	// SHL
+	case OP_SHL:
+		if quirks.Shift {
+			return fmt.Sprintf("[SHL] - registers[0x%X] *= 2 and set registers[0xF] to the high bit", x)
+		}
+		return fmt.Sprintf("[SHL] - registers[0x%X] = registers[0x%X] * 2 and set registers[0xF] to the high bit of registers[0x%X]", x, y, y)
+
+	// SNE
+	case OP_SNE_REG:
+		return fmt.Sprintf("[SNE] - Skip next instruction if registers[0x%X] != registers[0x%X]", x, y)
+
+	// LD
+	case OP_LD_I:
+		return fmt.Sprintf("[LD] - set I to 0x%X", nnn)
+
+	// JMP
+	case OP_JMP_V0:
+		if quirks.Jump {
+			return fmt.Sprintf("[JMP] - Jump to 0x%X + registers[0x%X]", nnn, x)
+		}
+		return fmt.Sprintf("[JMP] - Jump to 0x%X + registers[0x0]", nnn)
+
+	// RND
+	case OP_RND:
+		return fmt.Sprintf("[RND] - registers[0x%X] = rnd & 0x%X", x, kk)
+
+	// DRW
+	case OP_DRW:
+		var text string
+		if n == 0 && variant >= VARIANT_SCHIP {
+			text = fmt.Sprintf("[DRW] - draws 16x16 sprite from I to I + 31 starting at (registers[0x%X], registers[0x%X])", x, y)
+		} else if n == 0 {
+			text = fmt.Sprintf("[DRW] - draws nothing at (registers[0x%X], registers[0x%X]) and clears registers[0xF]", x, y)
+		} else {
+			text = fmt.Sprintf("[DRW] - draws sprite from I to I + %d starting at (registers[0x%X], registers[0x%X])", int(n) - 1, x, y)
+		}
+
+		if n != 0 || variant >= VARIANT_SCHIP {
+			if quirks.Clipping {
+				text += ", clipped at the edges"
+			} else {
+				text += ", wrapped around the edges"
+			}
+		}
+		if quirks.DisplayWait {
+			text += ", after waiting for the next frame"
+		}
+		return text
+
+	// SKP
+	case OP_SKP:
+		return fmt.Sprintf("[SKP] - Skip next instruction if key pressed == registers[0x%X]", x)
+
+	// SKNP
+	case OP_SKNP:
+		return fmt.Sprintf("[SKNP] - Skip next instruction if key pressed != registers[0x%X]", x)
+
+	// LONG - set I to the next word
+	case OP_LD_I_LONG:
+		return fmt.Sprintf("[LONG] - set I to 0x%04X", inst.Long)
+
+	// PLANE - select planes
+	case OP_PLANE:
+		return fmt.Sprintf("[PLANE] - Draw to planes 0x%X", x)
+
+	// LD - Load delay timer value into vx
+	case OP_LD_VX_DT:
+		return fmt.Sprintf("[LD] - registers[0x%X] = delay timer", x)
+
+	// LD - load from input
+	case OP_LD_VX_K:
+		return fmt.Sprintf("[LD] - registers[0x%X] = input", x)
+
+	// LD - Set delay timer
+	case OP_LD_DT_VX:
+		return fmt.Sprintf("[LD] - delay timer = registers[0x%X]", x)
+
+	// LD - Set sound timer
+	case OP_LD_ST_VX:
+		return fmt.Sprintf("[LD] - sound timer = registers[0x%X]", x)
+
+	// ADD - I and Vx
+	case OP_ADD_I_VX:
+		return fmt.Sprintf("[ADD] - I += registers[0x%X]", x)
+
+	// LD - Set I to the value of the location of the sprite
+	case OP_LD_F_VX:
+		return fmt.Sprintf("[LD] - I = location of sprite at registers[0x%X]", x)
+
+	// LD - Store BCD representation in to I, I+1, I+2
+	case OP_LD_B_VX:
+		return fmt.Sprintf("[LD] - Store BCD representation of registers[0x%X] into I to I + 2", x)
+
+	// LD - store registers in memory
+	case OP_STORE:
+		if quirks.LoadStore {
+			return fmt.Sprintf("[LD] - Store registers[0x0] to registers[0x%X] into memory[I] to memory[I + 0x%X] and I += 0x%X", x, x, int(x) + 1)
+		}
+		return fmt.Sprintf("[LD] - Store registers[0x0] to registers[0x%X] into memory[I] to memory[I + 0x%X]", x, x)
+
+	// LD - load register from memory
+	case OP_LOAD:
+		if quirks.LoadStore {
+			return fmt.Sprintf("[LD] - Load registers[0x0] to registers[0x%X] from memory[I] to memory[I + 0x%X] and I += 0x%X", x, x, int(x) + 1)
+		}
+		return fmt.Sprintf("[LD] - Load registers[0x0] to registers[0x%X] from memory[I] to memory[I + 0x%X]", x, x)
+
+	// LD - Set I to the location of the big sprite
+	case OP_LD_HF_VX:
+		return fmt.Sprintf("[LD] - I = location of big sprite at registers[0x%X]", x)
+
+	// LD - store registers in the user flags
+	case OP_LD_R_VX:
+		return fmt.Sprintf("[LD] - Store registers[0x0] to registers[0x%X] into user flags", x)
+
+	// LD - load registers from the user flags
+	case OP_LD_VX_R:
+		return fmt.Sprintf("[LD] - Load registers[0x0] to registers[0x%X] from user flags", x)
+
+	default:
+		return "[N/A] - Instruction not found"
+	}
+}
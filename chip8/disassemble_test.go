@@ -0,0 +1,54 @@
+package chip8
+
+
+import (
+	"strings"
+	"testing"
+)
+
+
+func TestDescribeOp(t *testing.T) {
+	tests := []struct {
+		name string
+		memory []byte
+		variant Variant
+		quirks Quirks
+		// text the description has to contain
+		contains string
+	}{
+		{"HIGH on CHIP-8", []byte{0x00, 0xFF}, VARIANT_CHIP8, QUIRKS_CHIP8, "schip instruction, not available on chip8"},
+		{"HIGH on SUPER-CHIP", []byte{0x00, 0xFF}, VARIANT_SCHIP, QUIRKS_SCHIP, "Switch to 128x64 resolution"},
+		{"LOW on CHIP-8", []byte{0x00, 0xFE}, VARIANT_CHIP8, QUIRKS_CHIP8, "not available on chip8"},
+		{"DXY0 on CHIP-8", []byte{0xD1, 0x20}, VARIANT_CHIP8, QUIRKS_CHIP8, "draws nothing"},
+		{"DXY0 on SUPER-CHIP", []byte{0xD1, 0x20}, VARIANT_SCHIP, QUIRKS_SCHIP, "16x16 sprite"},
+		{"DXYN", []byte{0xD1, 0x25}, VARIANT_CHIP8, QUIRKS_CHIP8, "from I to I + 4 starting at (registers[0x1], registers[0x2])"},
+		{"LONG", []byte{0xF0, 0x00, 0x12, 0x34}, VARIANT_XOCHIP, QUIRKS_XOCHIP, "set I to 0x1234"},
+		{"LONG on SUPER-CHIP", []byte{0xF0, 0x00, 0x12, 0x34}, VARIANT_SCHIP, QUIRKS_SCHIP, "xochip instruction, not available on schip"},
+		{"unknown", []byte{0x5A, 0xB9}, VARIANT_XOCHIP, QUIRKS_XOCHIP, "Instruction not found"},
+		{"SHR shifting Vx", []byte{0x81, 0x26}, VARIANT_CHIP8, QUIRKS_CHIP8, "registers[0x1] /= 2 and set registers[0xF] to the low bit"},
+		{"SHR shifting Vy", []byte{0x81, 0x26}, VARIANT_CHIP8, QUIRKS_VIP, "registers[0x1] = registers[0x2] / 2 and set registers[0xF] to the low bit of registers[0x2]"},
+		{"SHL shifting Vx", []byte{0x81, 0x2E}, VARIANT_CHIP8, QUIRKS_CHIP8, "registers[0x1] *= 2 and set registers[0xF] to the high bit"},
+		{"SHL shifting Vy", []byte{0x81, 0x2E}, VARIANT_CHIP8, QUIRKS_VIP, "registers[0x1] = registers[0x2] * 2 and set registers[0xF] to the high bit of registers[0x2]"},
+		{"JMP with V0", []byte{0xB2, 0x34}, VARIANT_CHIP8, QUIRKS_CHIP8, "0x234 + registers[0x0]"},
+		{"JMP with Vx", []byte{0xB2, 0x34}, VARIANT_SCHIP, QUIRKS_SCHIP, "0x234 + registers[0x2]"},
+		{"OR", []byte{0x81, 0x21}, VARIANT_CHIP8, QUIRKS_CHIP8, "registers[0x1] |= registers[0x2]"},
+		{"OR resetting VF", []byte{0x81, 0x21}, VARIANT_CHIP8, QUIRKS_VIP, "|= registers[0x2] and clear registers[0xF]"},
+		{"AND resetting VF", []byte{0x81, 0x22}, VARIANT_CHIP8, QUIRKS_VIP, "&= registers[0x2] and clear registers[0xF]"},
+		{"XOR resetting VF", []byte{0x81, 0x23}, VARIANT_CHIP8, QUIRKS_VIP, "^= registers[0x2] and clear registers[0xF]"},
+		{"STORE", []byte{0xF3, 0x55}, VARIANT_CHIP8, QUIRKS_CHIP8, "into memory[I] to memory[I + 0x3]"},
+		{"STORE moving I", []byte{0xF3, 0x55}, VARIANT_CHIP8, QUIRKS_VIP, "into memory[I] to memory[I + 0x3] and I += 0x4"},
+		{"LOAD moving I", []byte{0xF3, 0x65}, VARIANT_XOCHIP, QUIRKS_XOCHIP, "from memory[I] to memory[I + 0x3] and I += 0x4"},
+		{"DRW wrapping", []byte{0xD1, 0x25}, VARIANT_XOCHIP, QUIRKS_XOCHIP, ", wrapped around the edges"},
+		{"DRW clipping", []byte{0xD1, 0x25}, VARIANT_SCHIP, QUIRKS_SCHIP, ", clipped at the edges"},
+		{"DRW waiting", []byte{0xD1, 0x25}, VARIANT_CHIP8, QUIRKS_VIP, ", clipped at the edges, after waiting for the next frame"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := DescribeOp(DecodeAt(test.memory, 0), test.variant, test.quirks)
+			if !strings.Contains(text, test.contains) {
+				t.Errorf("Got %q, expected it to contain %q", text, test.contains)
+			}
+		})
+	}
+}
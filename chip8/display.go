@@ -0,0 +1,34 @@
+package chip8
+
+
+// Display is a frontend that shows the framebuffer of the system.
+// The framebuffer is indexed as frame[y][x], every pixel holds one bit per
+// bitplane so it is a colour from 0 to 3. Everything but XO-CHIP only uses
+// the first plane.
+type Display interface {
+	// Present shows the current contents of the framebuffer
+	Present(frame [][]byte) error
+
+	// Clear blanks the whole display
+	Clear() error
+
+	// Resize is called whenever the resolution of the framebuffer changes
+	Resize(width int, height int) error
+}
+
+
+// nullDisplay discards everything, useful when there is no terminal
+type nullDisplay struct{}
+
+
+func (nullDisplay) Present(frame [][]byte) error {
+	return nil
+}
+
+func (nullDisplay) Clear() error {
+	return nil
+}
+
+func (nullDisplay) Resize(width int, height int) error {
+	return nil
+}
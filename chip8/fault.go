@@ -0,0 +1,128 @@
+package chip8
+
+
+import (
+	"fmt"
+)
+
+
+// InvalidOpcode is an opcode that is not an instruction of the variant, or a
+// machine code call
+type InvalidOpcode struct {
+	PC uint16
+	Opcode uint16
+}
+
+func (fault *InvalidOpcode) Error() string {
+	return fmt.Sprintf("Invalid operation 0x%04X at 0x%03X", fault.Opcode, fault.PC)
+}
+
+
+// StackOverflow is a call with every entry of the stack in use
+type StackOverflow struct {
+	PC uint16
+	Opcode uint16
+}
+
+func (fault *StackOverflow) Error() string {
+	return fmt.Sprintf("Stack overflow by 0x%04X at 0x%03X", fault.Opcode, fault.PC)
+}
+
+
+// StackUnderflow is a return without a call to return from
+type StackUnderflow struct {
+	PC uint16
+	Opcode uint16
+}
+
+func (fault *StackUnderflow) Error() string {
+	return fmt.Sprintf("Stack underflow by 0x%04X at 0x%03X", fault.Opcode, fault.PC)
+}
+
+
+// MemoryFault is an instruction reading or writing outside of memory
+type MemoryFault struct {
+	PC uint16
+	Opcode uint16
+	Address int
+	Write bool
+}
+
+func (fault *MemoryFault) Error() string {
+	access := "Read from"
+	if fault.Write {
+		access = "Write to"
+	}
+
+	return fmt.Sprintf("%s 0x%04X outside of memory by 0x%04X at 0x%03X", access, fault.Address, fault.Opcode, fault.PC)
+}
+
+
+// KeyFault is EX9E or EXA1 checking a key that does not exist
+type KeyFault struct {
+	PC uint16
+	Opcode uint16
+	Key byte
+}
+
+func (fault *KeyFault) Error() string {
+	return fmt.Sprintf("Key 0x%02X does not exist, checked by 0x%04X at 0x%03X", fault.Key, fault.Opcode, fault.PC)
+}
+
+
+// ROMTooLarge is a ROM that does not fit into memory after PC_START
+type ROMTooLarge struct {
+	Size int
+	Max int
+}
+
+func (fault *ROMTooLarge) Error() string {
+	return fmt.Sprintf("ROM is %d bytes, at most %d fit into memory", fault.Size, fault.Max)
+}
+
+
+// invalidOpcode is the fault for the current instruction not existing
+func (sys *System) invalidOpcode() error {
+	return &InvalidOpcode{PC: sys.programCounter, Opcode: sys.opcode}
+}
+
+// address checks that an address is in memory, or wraps it around when
+// wrapping is enabled
+func (sys *System) address(address int, write bool) (int, error) {
+	if sys.wrapMemory {
+		address %= len(sys.memory)
+		if address < 0 {
+			address += len(sys.memory)
+		}
+		return address, nil
+	}
+
+	if address < 0 || address >= len(sys.memory) {
+		return address, &MemoryFault{PC: sys.programCounter, Opcode: sys.opcode, Address: address, Write: write}
+	}
+
+	return address, nil
+}
+
+// readMemory is the path every load from memory by an instruction takes
+func (sys *System) readMemory(address int) (byte, error) {
+	address, err := sys.address(address, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return sys.memory[address], nil
+}
+
+// key is the index of the key in register x
+func (sys *System) key(x uint8) (int, error) {
+	key := sys.registers[x]
+	if int(key) >= len(sys.keys) {
+		if !sys.wrapMemory {
+			return 0, &KeyFault{PC: sys.programCounter, Opcode: sys.opcode, Key: key}
+		}
+		key %= byte(len(sys.keys))
+	}
+
+	return int(key), nil
+}
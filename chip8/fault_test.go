@@ -0,0 +1,53 @@
+package chip8
+
+
+import (
+	"reflect"
+	"testing"
+)
+
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+		fault error
+	}{
+		{"00EE on an empty stack", []byte{0x00, 0xEE},
+			&StackUnderflow{PC: 0x200, Opcode: 0x00EE}},
+		{"FX65 past memory", []byte{0xAF, 0xFF, 0xF1, 0x65},
+			&MemoryFault{PC: 0x202, Opcode: 0xF165, Address: 0x1000, Write: false}},
+		{"FX55 past memory", []byte{0xAF, 0xFE, 0xF2, 0x55},
+			&MemoryFault{PC: 0x202, Opcode: 0xF255, Address: 0x1000, Write: true}},
+		{"EX9E on a missing key", []byte{0x60, 0x20, 0xE0, 0x9E},
+			&KeyFault{PC: 0x202, Opcode: 0xE09E, Key: 0x20}},
+		{"2NNN on a full stack", []byte{0x22, 0x00},
+			&StackOverflow{PC: 0x200, Opcode: 0x2200}},
+		// the second word is past memory, but the instruction doesn't exist
+		// on CHIP-8 in the first place
+		{"F000 at the end of memory", []byte{0x1F, 0xFE},
+			&InvalidOpcode{PC: 0xFFE, Opcode: 0xF000}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the rest of memory is 0x0000, which exits, up to an F000 at 0xFFE
+			rom := make([]byte, MEMORY_SIZE - PC_START)
+			copy(rom, test.rom)
+			rom[len(rom) - 2] = 0xF0
+			sys := newTestSystem(t, rom)
+
+			err := sys.RunCycles(32)
+			if !reflect.DeepEqual(err, test.fault) {
+				t.Fatalf("Got %#v, expected %#v", err, test.fault)
+			}
+			if !reflect.DeepEqual(sys.Fault(), test.fault) {
+				t.Errorf("Fault is %#v, expected %#v", sys.Fault(), test.fault)
+			}
+
+			if sys.HaltReason() != HALT_FAULT {
+				t.Errorf("Halt reason is %s, expected %s", sys.HaltReason(), HALT_FAULT)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package chip8
+
+
+import (
+	"sort"
+)
+
+
+// ByteKind is what a byte of a ROM turned out to be when following its
+// control flow
+type ByteKind int
+
+const (
+	// never reached nor referenced
+	BYTE_UNREACHABLE ByteKind = iota
+	// first byte of a reached instruction
+	BYTE_CODE
+	// other bytes of a reached instruction
+	BYTE_OPERAND
+	// read or written through I
+	BYTE_DATA
+	// drawn through I
+	BYTE_SPRITE
+)
+
+
+// Region is a range of addresses from Start up to but not including End
+type Region struct {
+	Start int
+	End int
+}
+
+
+// Flow is what following the control flow of a ROM from its start found out
+// about its bytes. Computed jumps with JMP V0 are only followed into a table
+// of jumps, so code reached otherwise shows up as unreachable.
+type Flow struct {
+	rom []byte
+	variant Variant
+
+	// by offset into the ROM
+	Kinds []ByteKind
+	// reached instructions by address
+	Instructions map[int]Instruction
+	// addresses that are called
+	Subroutines map[int]bool
+	// where execution goes after an instruction, after a call this is the
+	// instruction following it
+	Successors map[int][]int
+	// memory read, written or drawn through I by an instruction, where I is
+	// known
+	Accesses map[int][]Region
+}
+
+// flowState is what is known about the machine when reaching an address
+type flowState struct {
+	address int
+	// -1 when unknown
+	i int
+	planes int
+}
+
+
+// FollowFlow finds the instructions of a ROM by following jumps, calls and
+// skips from its start and marks the data they refer to through I
+func FollowFlow(rom []byte, variant Variant) *Flow {
+	flow := new(Flow)
+	flow.rom = rom
+	flow.variant = variant
+	flow.Kinds = make([]ByteKind, len(rom))
+	flow.Instructions = make(map[int]Instruction)
+	flow.Subroutines = make(map[int]bool)
+	flow.Successors = make(map[int][]int)
+	flow.Accesses = make(map[int][]Region)
+
+	// references through I, applied once all code is known
+	data := []Region{}
+	sprites := []Region{}
+
+	seen := make(map[flowState]bool)
+	pending := []flowState{{address: PC_START, i: -1, planes: 1}}
+	follow := func(from int, to flowState) {
+		flow.link(from, to.address)
+		pending = append(pending, to)
+	}
+	for len(pending) > 0 {
+		state := pending[len(pending) - 1]
+		pending = pending[:len(pending) - 1]
+		if seen[state] {
+			continue
+		}
+		seen[state] = true
+
+		inst, ok := flow.decode(state.address)
+		if !ok {
+			continue
+		}
+
+		next := state
+		next.address += int(inst.Size())
+
+		switch inst.Op {
+		case OP_RET, OP_EXIT:
+			continue
+
+		case OP_SYS:
+			if inst.Halts() {
+				continue
+			}
+			break
+
+		case OP_JMP:
+			next.address = int(inst.Address)
+			break
+
+		case OP_CALL:
+			flow.Subroutines[int(inst.Address)] = true
+			called := state
+			called.address = int(inst.Address)
+			pending = append(pending, called)
+			// the subroutine may have changed I
+			next.i = -1
+			break
+
+		case OP_JMP_V0:
+			// follow a table of jumps, as far as it goes
+			for entry := int(inst.Address); ; entry += 2 {
+				target, ok := flow.decode(entry)
+				if !ok || target.Op != OP_JMP {
+					break
+				}
+				jump := state
+				jump.address = entry
+				follow(state.address, jump)
+			}
+			continue
+
+		case OP_SE_BYTE, OP_SNE_BYTE, OP_SE_REG, OP_SNE_REG, OP_SKP, OP_SKNP:
+			skipped := next
+			if following, ok := flow.decode(next.address); ok {
+				skipped.address += int(following.Size())
+			} else {
+				skipped.address += 2
+			}
+			follow(state.address, skipped)
+			break
+
+		case OP_LD_I:
+			next.i = int(inst.Address)
+			data = append(data, Region{next.i, next.i + 1})
+			break
+
+		case OP_LD_I_LONG:
+			next.i = int(inst.Long)
+			data = append(data, Region{next.i, next.i + 1})
+			break
+
+		case OP_ADD_I_VX, OP_LD_F_VX, OP_LD_HF_VX:
+			next.i = -1
+			break
+
+		case OP_STORE, OP_LOAD:
+			if state.i >= 0 {
+				data = append(data, flow.access(state.address, Region{state.i, state.i + int(inst.X) + 1}))
+			}
+			break
+
+		case OP_LD_B_VX:
+			if state.i >= 0 {
+				data = append(data, flow.access(state.address, Region{state.i, state.i + 3}))
+			}
+			break
+
+		case OP_PLANE:
+			next.planes = 0
+			for plane := uint8(0); plane < PLANE_COUNT; plane++ {
+				if inst.X & (1 << plane) != 0 {
+					next.planes++
+				}
+			}
+			break
+
+		case OP_DRW:
+			size := int(inst.N)
+			if size == 0 && flow.variant >= VARIANT_SCHIP {
+				size = 32
+			}
+			if state.i >= 0 {
+				sprites = append(sprites, flow.access(state.address, Region{state.i, state.i + size * state.planes}))
+			}
+			break
+		}
+
+		follow(state.address, next)
+	}
+
+	flow.mark(data, BYTE_DATA)
+	flow.mark(sprites, BYTE_SPRITE)
+
+	return flow
+}
+
+// decode reads the instruction at address and claims its bytes as code,
+// unless it is outside the ROM, not an instruction of the variant or
+// overlaps another instruction
+func (flow *Flow) decode(address int) (Instruction, bool) {
+	if inst, ok := flow.Instructions[address]; ok {
+		return inst, true
+	}
+
+	offset := address - PC_START
+	if offset < 0 || offset + 1 >= len(flow.rom) {
+		return Instruction{}, false
+	}
+
+	inst := DecodeAt(flow.rom, offset)
+	size := int(inst.Size())
+	if !inst.Valid() || inst.Variant() > flow.variant || offset + size > len(flow.rom) {
+		return inst, false
+	}
+	for i := offset; i < offset + size; i++ {
+		if flow.Kinds[i] != BYTE_UNREACHABLE {
+			return inst, false
+		}
+	}
+
+	flow.Kinds[offset] = BYTE_CODE
+	for i := offset + 1; i < offset + size; i++ {
+		flow.Kinds[i] = BYTE_OPERAND
+	}
+	flow.Instructions[address] = inst
+
+	return inst, true
+}
+
+// link records that execution can go from one address to another
+func (flow *Flow) link(from int, to int) {
+	for _, successor := range flow.Successors[from] {
+		if successor == to {
+			return
+		}
+	}
+
+	flow.Successors[from] = append(flow.Successors[from], to)
+}
+
+// access records the memory an instruction uses
+func (flow *Flow) access(address int, region Region) Region {
+	for _, other := range flow.Accesses[address] {
+		if other == region {
+			return region
+		}
+	}
+
+	flow.Accesses[address] = append(flow.Accesses[address], region)
+	return region
+}
+
+// mark sets the kind of the bytes of regions that are not code
+func (flow *Flow) mark(regions []Region, kind ByteKind) {
+	for _, region := range regions {
+		for address := region.Start; address < region.End; address++ {
+			offset := address - PC_START
+			if offset < 0 || offset >= len(flow.rom) {
+				continue
+			}
+
+			if flow.Kinds[offset] == BYTE_UNREACHABLE || flow.Kinds[offset] == BYTE_DATA {
+				flow.Kinds[offset] = kind
+			}
+		}
+	}
+}
+
+// Kind is what the byte at address is
+func (flow *Flow) Kind(address int) ByteKind {
+	offset := address - PC_START
+	if offset < 0 || offset >= len(flow.rom) {
+		return BYTE_UNREACHABLE
+	}
+
+	return flow.Kinds[offset]
+}
+
+// Unreachable are the regions of the ROM that are neither reached nor
+// referenced
+func (flow *Flow) Unreachable() []Region {
+	regions := []Region{}
+	for offset := 0; offset < len(flow.rom); offset++ {
+		if flow.Kinds[offset] != BYTE_UNREACHABLE {
+			continue
+		}
+
+		start := offset
+		for offset < len(flow.rom) && flow.Kinds[offset] == BYTE_UNREACHABLE {
+			offset++
+		}
+		regions = append(regions, Region{PC_START + start, PC_START + offset})
+	}
+
+	return regions
+}
+
+// Addresses are the addresses of the reached instructions in order
+func (flow *Flow) Addresses() []int {
+	addresses := make([]int, 0, len(flow.Instructions))
+	for address := range flow.Instructions {
+		addresses = append(addresses, address)
+	}
+	sort.Ints(addresses)
+
+	return addresses
+}
@@ -0,0 +1,90 @@
+package chip8
+
+
+import (
+	"reflect"
+	"testing"
+)
+
+
+// letters for the kinds of bytes, to compare them as text
+var KIND_LETTERS = map[ByteKind]byte{
+	BYTE_UNREACHABLE: '.',
+	BYTE_CODE: 'C',
+	BYTE_OPERAND: 'o',
+	BYTE_DATA: 'd',
+	BYTE_SPRITE: 's',
+}
+
+// a sprite, a subroutine storing registers and unreachable words
+var FLOW_TEST_ROM = []byte{
+	0xA2, 0x10, 0xD0, 0x12, 0x22, 0x0A, 0x12, 0x06,
+	0x00, 0x00, 0xA2, 0x12, 0xF1, 0x55, 0x00, 0xEE,
+	0xF0, 0x90, 0x12, 0x34, 0x00, 0x00,
+}
+
+
+func kindLetters(flow *Flow) string {
+	letters := make([]byte, len(flow.Kinds))
+	for i, kind := range flow.Kinds {
+		letters[i] = KIND_LETTERS[kind]
+	}
+
+	return string(letters)
+}
+
+
+func TestFollowFlowKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+		variant Variant
+		kinds string
+	}{
+		{"code and data", FLOW_TEST_ROM, VARIANT_CHIP8, "CoCoCoCo..CoCoCossdd.."},
+		// the skip goes past all 4 bytes of the long load
+		{"skip over LONG", []byte{
+			0x30, 0x00, 0xF0, 0x00, 0x02, 0x08, 0x00, 0xFD,
+		}, VARIANT_XOCHIP, "CoCoooCo"},
+		// only the jumps of the table are followed
+		{"jump table", []byte{
+			0xB2, 0x04, 0x00, 0x00, 0x12, 0x08, 0x12, 0x0A,
+			0x00, 0xE0, 0x12, 0x0A,
+		}, VARIANT_CHIP8, "Co..CoCoCoCo"},
+		{"jump into an instruction", []byte{0x12, 0x03, 0x60, 0x12, 0x06}, VARIANT_CHIP8, "Co.Co"},
+		// 00FF is no instruction of CHIP-8, so nothing after it runs
+		{"variant", []byte{0x00, 0xE0, 0x00, 0xFF, 0x12, 0x00}, VARIANT_CHIP8, "Co...."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flow := FollowFlow(test.rom, test.variant)
+			if kinds := kindLetters(flow); kinds != test.kinds {
+				t.Errorf("Got %s, expected %s", kinds, test.kinds)
+			}
+		})
+	}
+}
+
+func TestFollowFlowStructure(t *testing.T) {
+	flow := FollowFlow(FLOW_TEST_ROM, VARIANT_CHIP8)
+
+	if addresses := flow.Addresses(); !reflect.DeepEqual(addresses, []int{0x200, 0x202, 0x204, 0x206, 0x20A, 0x20C, 0x20E}) {
+		t.Errorf("Reached %X", addresses)
+	}
+	if !reflect.DeepEqual(flow.Subroutines, map[int]bool{0x20A: true}) {
+		t.Errorf("Subroutines are %v", flow.Subroutines)
+	}
+	if successors := flow.Successors[0x204]; !reflect.DeepEqual(successors, []int{0x206}) {
+		t.Errorf("CALL continues at %X, expected after it", successors)
+	}
+	if accesses := flow.Accesses[0x202]; !reflect.DeepEqual(accesses, []Region{{0x210, 0x212}}) {
+		t.Errorf("DRW draws %v", accesses)
+	}
+	if accesses := flow.Accesses[0x20C]; !reflect.DeepEqual(accesses, []Region{{0x212, 0x214}}) {
+		t.Errorf("STORE writes %v", accesses)
+	}
+	if unreachable := flow.Unreachable(); !reflect.DeepEqual(unreachable, []Region{{0x208, 0x20A}, {0x214, 0x216}}) {
+		t.Errorf("Unreachable are %v", unreachable)
+	}
+}
@@ -0,0 +1,68 @@
+package chip8
+
+
+import (
+	"sync"
+)
+
+
+// KeyEvent is a press or release of one of the 16 keys
+type KeyEvent struct {
+	Key byte
+	Pressed bool
+}
+
+
+// Input is a source of key events for the system. The system applies the
+// events to its own 16-key state, so it does not matter whether they come
+// from a terminal, a script or the network.
+type Input interface {
+	// Events returns the events since the last call without blocking
+	Events() []KeyEvent
+}
+
+
+// QueueInput is an Input that is fed by calling Press and Release, and is
+// safe to feed from other goroutines
+type QueueInput struct {
+	lock sync.Mutex
+	events []KeyEvent
+}
+
+
+func NewQueueInput() *QueueInput {
+	return new(QueueInput)
+}
+
+
+func (input *QueueInput) Press(key byte) {
+	input.push(KeyEvent{Key: key, Pressed: true})
+}
+
+func (input *QueueInput) Release(key byte) {
+	input.push(KeyEvent{Key: key, Pressed: false})
+}
+
+func (input *QueueInput) push(event KeyEvent) {
+	input.lock.Lock()
+	input.events = append(input.events, event)
+	input.lock.Unlock()
+}
+
+func (input *QueueInput) Events() []KeyEvent {
+	input.lock.Lock()
+	events := input.events
+	input.events = nil
+	input.lock.Unlock()
+
+	return events
+}
+
+
+// nullInput never reports any key
+type nullInput struct{}
+
+
+func (nullInput) Events() []KeyEvent {
+	return nil
+}
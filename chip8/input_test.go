@@ -0,0 +1,82 @@
+package chip8
+
+
+import (
+	"reflect"
+	"testing"
+)
+
+
+func TestQueueInputEvents(t *testing.T) {
+	input := NewQueueInput()
+	input.Press(0x1)
+	input.Press(0xA)
+	input.Release(0x1)
+
+	expected := []KeyEvent{{0x1, true}, {0xA, true}, {0x1, false}}
+	if events := input.Events(); !reflect.DeepEqual(events, expected) {
+		t.Errorf("Got %v, expected %v", events, expected)
+	}
+	if events := input.Events(); len(events) != 0 {
+		t.Errorf("Events were not drained: %v", events)
+	}
+}
+
+func TestInputSetsKeys(t *testing.T) {
+	// V0 = 5, SKP V0, then SKNP V0 at 0x204 and 0x206
+	rom := []byte{0x60, 0x05, 0xE0, 0x9E, 0xE0, 0xA1, 0xE0, 0xA1}
+
+	tests := []struct {
+		name string
+		events func(input *QueueInput)
+		// PC after SKP and after the SKNP following it, a press is used up
+		// by the first check that sees it
+		pcs [2]uint16
+	}{
+		{"no key", func(input *QueueInput) {}, [2]uint16{0x204, 0x208}},
+		{"pressed", func(input *QueueInput) { input.Press(5) }, [2]uint16{0x206, 0x20A}},
+		{"other key", func(input *QueueInput) { input.Press(6) }, [2]uint16{0x204, 0x208}},
+		{"released", func(input *QueueInput) { input.Press(5); input.Release(5) }, [2]uint16{0x204, 0x208}},
+		{"past the keys", func(input *QueueInput) { input.Press(0x15) }, [2]uint16{0x204, 0x208}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := NewQueueInput()
+			sys := newTestSystem(t, rom, WithInput(input))
+			sys.Step()
+
+			test.events(input)
+			for _, pc := range test.pcs {
+				_, err := sys.Step()
+				if err != nil {
+					t.Fatalf("Error stepping: %v", err)
+				}
+				if sys.Registers().PC != pc {
+					t.Fatalf("PC is 0x%03X, expected 0x%03X", sys.Registers().PC, pc)
+				}
+			}
+		})
+	}
+}
+
+func TestInputEndsWaitForKey(t *testing.T) {
+	// LD V3, K, then loop
+	rom := []byte{0xF3, 0x0A, 0x12, 0x02}
+	input := NewQueueInput()
+	sys := newTestSystem(t, rom, WithInput(input))
+
+	for i := 0; i < 5; i++ {
+		sys.Step()
+	}
+	if sys.Registers().PC != 0x200 {
+		t.Fatalf("Stopped waiting at 0x%03X without a key", sys.Registers().PC)
+	}
+
+	input.Press(0xB)
+	input.Press(0xC)
+	sys.Step()
+	if registers := sys.Registers(); registers.PC != 0x202 || registers.V[3] != 0xB {
+		t.Errorf("PC is 0x%03X and V3 0x%X, expected 0x202 and the first key pressed, 0xB", registers.PC, registers.V[3])
+	}
+}
@@ -0,0 +1,928 @@
+package chip8
+
+
+import (
+	"fmt"
+	"strings"
+)
+
+
+// Op identifies an instruction regardless of its operands
+type Op int
+
+const (
+	OP_INVALID Op = iota
+	OP_SYS
+	OP_CLS
+	OP_RET
+	OP_JMP
+	OP_CALL
+	OP_SE_BYTE
+	OP_SNE_BYTE
+	OP_SE_REG
+	OP_LD_BYTE
+	OP_ADD_BYTE
+	OP_LD_REG
+	OP_OR
+	OP_AND
+	OP_XOR
+	OP_ADD_REG
+	OP_SUB
+	OP_SHR
+	OP_SUBN
+	OP_SHL
+	OP_SNE_REG
+	OP_LD_I
+	OP_JMP_V0
+	OP_RND
+	OP_DRW
+	OP_SKP
+	OP_SKNP
+	OP_LD_VX_DT
+	OP_LD_VX_K
+	OP_LD_DT_VX
+	OP_LD_ST_VX
+	OP_ADD_I_VX
+	OP_LD_F_VX
+	OP_LD_B_VX
+	OP_STORE
+	OP_LOAD
+
+	// SUPER-CHIP
+	OP_SCD
+	OP_SCR
+	OP_SCL
+	OP_EXIT
+	OP_LOW
+	OP_HIGH
+	OP_LD_HF_VX
+	OP_LD_R_VX
+	OP_LD_VX_R
+
+	// XO-CHIP
+	OP_SCU
+	OP_SAVE
+	OP_LOAD_RANGE
+	OP_LD_I_LONG
+	OP_PLANE
+)
+
+
+/* Operands are written as
+ * Vx, Vy - register from the x or y nibble
+ * nnn - 12 bit address
+ * nnnn - 16 bit address in the word after the opcode
+ * kk - byte
+ * n - nibble
+ * x - the x nibble as a number
+ * anything else is literal text, such as I, DT or [I]
+ */
+type opSpec struct {
+	op Op
+	mnemonic string
+	operands []string
+	// the opcode matches when opcode & mask == pattern
+	pattern uint16
+	mask uint16
+	// the first variant with this instruction
+	variant Variant
+}
+
+
+// every instruction, in the order they are matched
+var OP_SPECS = []opSpec{
+	{OP_CLS, "CLS", nil, 0x00E0, 0xFFFF, VARIANT_CHIP8},
+	{OP_SCU, "SCU", []string{"n"}, 0x00D0, 0xFFF0, VARIANT_XOCHIP},
+	{OP_RET, "RET", nil, 0x00EE, 0xFFFF, VARIANT_CHIP8},
+	{OP_SCD, "SCD", []string{"n"}, 0x00C0, 0xFFF0, VARIANT_SCHIP},
+	{OP_SCR, "SCR", nil, 0x00FB, 0xFFFF, VARIANT_SCHIP},
+	{OP_SCL, "SCL", nil, 0x00FC, 0xFFFF, VARIANT_SCHIP},
+	{OP_EXIT, "EXIT", nil, 0x00FD, 0xFFFF, VARIANT_SCHIP},
+	{OP_LOW, "LOW", nil, 0x00FE, 0xFFFF, VARIANT_SCHIP},
+	{OP_HIGH, "HIGH", nil, 0x00FF, 0xFFFF, VARIANT_SCHIP},
+	{OP_SYS, "SYS", []string{"nnn"}, 0x0000, 0xF000, VARIANT_CHIP8},
+	{OP_JMP, "JMP", []string{"nnn"}, 0x1000, 0xF000, VARIANT_CHIP8},
+	{OP_CALL, "CALL", []string{"nnn"}, 0x2000, 0xF000, VARIANT_CHIP8},
+	{OP_SE_BYTE, "SE", []string{"Vx", "kk"}, 0x3000, 0xF000, VARIANT_CHIP8},
+	{OP_SNE_BYTE, "SNE", []string{"Vx", "kk"}, 0x4000, 0xF000, VARIANT_CHIP8},
+	{OP_SE_REG, "SE", []string{"Vx", "Vy"}, 0x5000, 0xF00F, VARIANT_CHIP8},
+	{OP_SAVE, "SAVE", []string{"Vx", "Vy"}, 0x5002, 0xF00F, VARIANT_XOCHIP},
+	{OP_LOAD_RANGE, "LOAD", []string{"Vx", "Vy"}, 0x5003, 0xF00F, VARIANT_XOCHIP},
+	{OP_LD_BYTE, "LD", []string{"Vx", "kk"}, 0x6000, 0xF000, VARIANT_CHIP8},
+	{OP_ADD_BYTE, "ADD", []string{"Vx", "kk"}, 0x7000, 0xF000, VARIANT_CHIP8},
+	{OP_LD_REG, "LD", []string{"Vx", "Vy"}, 0x8000, 0xF00F, VARIANT_CHIP8},
+	{OP_OR, "OR", []string{"Vx", "Vy"}, 0x8001, 0xF00F, VARIANT_CHIP8},
+	{OP_AND, "AND", []string{"Vx", "Vy"}, 0x8002, 0xF00F, VARIANT_CHIP8},
+	{OP_XOR, "XOR", []string{"Vx", "Vy"}, 0x8003, 0xF00F, VARIANT_CHIP8},
+	{OP_ADD_REG, "ADD", []string{"Vx", "Vy"}, 0x8004, 0xF00F, VARIANT_CHIP8},
+	{OP_SUB, "SUB", []string{"Vx", "Vy"}, 0x8005, 0xF00F, VARIANT_CHIP8},
+	{OP_SHR, "SHR", []string{"Vx", "Vy"}, 0x8006, 0xF00F, VARIANT_CHIP8},
+	{OP_SUBN, "SUBN", []string{"Vx", "Vy"}, 0x8007, 0xF00F, VARIANT_CHIP8},
+	{OP_SHL, "SHL", []string{"Vx", "Vy"}, 0x800E, 0xF00F, VARIANT_CHIP8},
+	{OP_SNE_REG, "SNE", []string{"Vx", "Vy"}, 0x9000, 0xF00F, VARIANT_CHIP8},
+	{OP_LD_I, "LD", []string{"I", "nnn"}, 0xA000, 0xF000, VARIANT_CHIP8},
+	{OP_JMP_V0, "JMP", []string{"V0", "nnn"}, 0xB000, 0xF000, VARIANT_CHIP8},
+	{OP_RND, "RND", []string{"Vx", "kk"}, 0xC000, 0xF000, VARIANT_CHIP8},
+	{OP_DRW, "DRW", []string{"Vx", "Vy", "n"}, 0xD000, 0xF000, VARIANT_CHIP8},
+	{OP_SKP, "SKP", []string{"Vx"}, 0xE09E, 0xF0FF, VARIANT_CHIP8},
+	{OP_SKNP, "SKNP", []string{"Vx"}, 0xE0A1, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_I_LONG, "LONG", []string{"I", "nnnn"}, 0xF000, 0xFFFF, VARIANT_XOCHIP},
+	{OP_PLANE, "PLANE", []string{"x"}, 0xF001, 0xF0FF, VARIANT_XOCHIP},
+	{OP_LD_VX_DT, "LD", []string{"Vx", "DT"}, 0xF007, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_VX_K, "LD", []string{"Vx", "K"}, 0xF00A, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_DT_VX, "LD", []string{"DT", "Vx"}, 0xF015, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_ST_VX, "LD", []string{"ST", "Vx"}, 0xF018, 0xF0FF, VARIANT_CHIP8},
+	{OP_ADD_I_VX, "ADD", []string{"I", "Vx"}, 0xF01E, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_F_VX, "LD", []string{"F", "Vx"}, 0xF029, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_B_VX, "LD", []string{"B", "Vx"}, 0xF033, 0xF0FF, VARIANT_CHIP8},
+	{OP_STORE, "LD", []string{"[I]", "Vx"}, 0xF055, 0xF0FF, VARIANT_CHIP8},
+	{OP_LOAD, "LD", []string{"Vx", "[I]"}, 0xF065, 0xF0FF, VARIANT_CHIP8},
+	{OP_LD_HF_VX, "LD", []string{"HF", "Vx"}, 0xF030, 0xF0FF, VARIANT_SCHIP},
+	{OP_LD_R_VX, "LD", []string{"R", "Vx"}, 0xF075, 0xF0FF, VARIANT_SCHIP},
+	{OP_LD_VX_R, "LD", []string{"Vx", "R"}, 0xF085, 0xF0FF, VARIANT_SCHIP},
+}
+
+
+// Instruction is a decoded opcode. Only the operands the instruction uses
+// are meaningful.
+type Instruction struct {
+	Opcode uint16
+	Op Op
+	X uint8
+	Y uint8
+	Address uint16
+	N uint8
+	K uint8
+	// second word of a 4 byte instruction
+	Long uint16
+
+	spec *opSpec
+}
+
+
+// Decode splits an opcode into the instruction and its operands, unknown
+// opcodes decode to OP_INVALID
+func Decode(opcode uint16) Instruction {
+	inst := Instruction{
+		Opcode: opcode,
+		Op: OP_INVALID,
+		X: uint8((opcode & 0x0F00) >> 8),
+		Y: uint8((opcode & 0x00F0) >> 4),
+		Address: opcode & 0x0FFF,
+		N: uint8(opcode & 0x000F),
+		K: uint8(opcode & 0x00FF),
+	}
+
+	for i := range OP_SPECS {
+		if opcode & OP_SPECS[i].mask == OP_SPECS[i].pattern {
+			inst.Op = OP_SPECS[i].op
+			inst.spec = &OP_SPECS[i]
+			break
+		}
+	}
+
+	return inst
+}
+
+// DecodeAt decodes the instruction at address in memory, including the second
+// word of a 4 byte instruction. Bytes outside of memory read as 0.
+func DecodeAt(memory []byte, address int) Instruction {
+	inst := Decode(readWord(memory, address))
+	if inst.Size() == 4 {
+		inst.Long = readWord(memory, address + 2)
+	}
+
+	return inst
+}
+
+func readWord(memory []byte, address int) uint16 {
+	var word uint16
+	if address >= 0 && address < len(memory) {
+		word = uint16(memory[address]) << 8
+	}
+	if address + 1 >= 0 && address + 1 < len(memory) {
+		word |= uint16(memory[address + 1])
+	}
+
+	return word
+}
+
+// Size is the length of the instruction in bytes
+func (inst Instruction) Size() uint16 {
+	if inst.Op == OP_LD_I_LONG {
+		return 4
+	}
+
+	return 2
+}
+
+// Valid reports whether the opcode is a known instruction
+func (inst Instruction) Valid() bool {
+	return inst.Op != OP_INVALID
+}
+
+// Halts reports whether the instruction exits the program. Besides EXIT,
+// 0x0000 and 0x0A00 are treated as an exit rather than a machine code routine.
+func (inst Instruction) Halts() bool {
+	return inst.Op == OP_EXIT || (inst.Op == OP_SYS && (inst.Address == 0x000 || inst.Address == 0xA00))
+}
+
+// Variant is the first platform that has the instruction
+func (inst Instruction) Variant() Variant {
+	if inst.spec == nil {
+		return VARIANT_CHIP8
+	}
+
+	return inst.spec.variant
+}
+
+// Mnemonic is the name of the instruction, such as LD or JMP
+func (inst Instruction) Mnemonic() string {
+	if inst.spec == nil {
+		return "dw"
+	}
+
+	return inst.spec.mnemonic
+}
+
+// Operands are the operands of the instruction in assembler syntax
+func (inst Instruction) Operands() []string {
+	if inst.spec == nil {
+		return []string{fmt.Sprintf("0x%04X", inst.Opcode)}
+	}
+
+	operands := make([]string, len(inst.spec.operands))
+	for i, operand := range inst.spec.operands {
+		switch operand {
+		case "Vx":
+			operands[i] = fmt.Sprintf("V%X", inst.X)
+		case "Vy":
+			operands[i] = fmt.Sprintf("V%X", inst.Y)
+		case "nnn":
+			operands[i] = fmt.Sprintf("0x%03X", inst.Address)
+		case "nnnn":
+			operands[i] = fmt.Sprintf("0x%04X", inst.Long)
+		case "kk":
+			operands[i] = fmt.Sprintf("0x%02X", inst.K)
+		case "n":
+			operands[i] = fmt.Sprintf("%d", inst.N)
+		case "x":
+			operands[i] = fmt.Sprintf("%d", inst.X)
+		default:
+			operands[i] = operand
+		}
+	}
+
+	return operands
+}
+
+// String formats the instruction in assembler syntax, such as LD V1, 0x20.
+// Unknown opcodes are formatted as a dw directive.
+func (inst Instruction) String() string {
+	operands := inst.Operands()
+	if len(operands) == 0 {
+		return inst.Mnemonic()
+	}
+
+	return inst.Mnemonic() + " " + strings.Join(operands, ", ")
+}
+
+
+func (sys *System) parseInstruction() error {
+	inst := Decode(sys.opcode)
+	// an instruction of a later variant is invalid, however long it would be
+	if inst.Variant() > sys.variant {
+		return sys.invalidOpcode()
+	}
+
+	if inst.Size() == 4 {
+		high, err := sys.readMemory(int(sys.programCounter) + 2)
+		if err != nil {
+			return err
+		}
+		low, err := sys.readMemory(int(sys.programCounter) + 3)
+		if err != nil {
+			return err
+		}
+		inst.Long = (uint16(high) << 8) | uint16(low)
+	}
+
+	x := inst.X
+	y := inst.Y
+	n := uint16(inst.N)
+	nnn := inst.Address
+	kk := inst.K
+
+	switch inst.Op {
+	// CLS - Clear display
+	case OP_CLS:
+		sys.clearDisplay()
+		err := sys.screen.Clear()
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// RET - return from subroutine
+	case OP_RET:
+		addr, err := sys.stack.pop()
+		if err != nil {
+			return &StackUnderflow{PC: sys.programCounter, Opcode: sys.opcode}
+		}
+		sys.programCounter = addr
+
+		sys.incrementPC(false)
+		break
+
+	// SYS - jump to machine code routine at address
+	// will not implement, except for 0x0000 and 0x0A00 which exit
+	case OP_SYS:
+		if !inst.Halts() {
+			return sys.invalidOpcode()
+		}
+
+		sys.haltReason = HALT_EXIT
+
+		sys.incrementPC(false)
+		break
+
+	// SCD - scroll display down n lines
+	case OP_SCD:
+		sys.scrollDisplay(0, int(n))
+		err := sys.screen.Present(sys.display)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// SCR - scroll display right 4 pixels
+	case OP_SCR:
+		sys.scrollDisplay(4, 0)
+		err := sys.screen.Present(sys.display)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// SCL - scroll display left 4 pixels
+	case OP_SCL:
+		sys.scrollDisplay(-4, 0)
+		err := sys.screen.Present(sys.display)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// SCU - scroll display up n lines
+	case OP_SCU:
+		sys.scrollDisplay(0, -int(n))
+		err := sys.screen.Present(sys.display)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// EXIT - exit the interpreter
+	case OP_EXIT:
+		sys.haltReason = HALT_EXIT
+
+		sys.incrementPC(false)
+		break
+
+	// LOW - switch to 64x32
+	case OP_LOW:
+		err := sys.setResolution(false)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// HIGH - switch to 128x64
+	case OP_HIGH:
+		err := sys.setResolution(true)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// JMP - jump to address
+	case OP_JMP:
+		sys.programCounter = nnn
+		break
+
+	// CALL - call subroutine
+	case OP_CALL:
+		err := sys.stack.push(sys.programCounter)
+		if err != nil {
+			return &StackOverflow{PC: sys.programCounter, Opcode: sys.opcode}
+		}
+		sys.programCounter = nnn
+
+		break
+
+	// SE - Skip next instruction if Vx == val
+	case OP_SE_BYTE:
+		if sys.registers[x] == kk {
+			sys.incrementPC(true)
+		} else {
+			sys.incrementPC(false)
+		}
+		break
+
+	// SNE - skip next instruction if Vx != val
+	case OP_SNE_BYTE:
+		if sys.registers[x] == kk {
+			sys.incrementPC(false)
+		} else {
+			sys.incrementPC(true)
+		}
+		break
+
+	// SE - skip if Vx == Vy
+	case OP_SE_REG:
+		if sys.registers[x] == sys.registers[y] {
+			sys.incrementPC(true)
+		} else {
+			sys.incrementPC(false)
+		}
+		break
+
+	// SAVE - store Vx to Vy in memory starting at I, in either order
+	case OP_SAVE:
+		step := 1
+		if x > y {
+			step = -1
+		}
+
+		for i := 0; ; i++ {
+			register := int(x) + i * step
+			err := sys.writeMemory(int(sys.iregister) + i, sys.registers[register])
+			if err != nil {
+				return err
+			}
+
+			if register == int(y) {
+				break
+			}
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LOAD - load Vx to Vy from memory starting at I, in either order
+	case OP_LOAD_RANGE:
+		step := 1
+		if x > y {
+			step = -1
+		}
+
+		for i := 0; ; i++ {
+			register := int(x) + i * step
+			value, err := sys.readMemory(int(sys.iregister) + i)
+			if err != nil {
+				return err
+			}
+			sys.registers[register] = value
+
+			if register == int(y) {
+				break
+			}
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LD - sets register
+	case OP_LD_BYTE:
+		sys.registers[x] = kk
+
+		sys.incrementPC(false)
+		break
+
+	// ADD - Vx = Vx + val
+	case OP_ADD_BYTE:
+		sys.registers[x] += kk
+
+		sys.incrementPC(false)
+		break
+
+	// LD - set register
+	case OP_LD_REG:
+		sys.registers[x] = sys.registers[y]
+
+		sys.incrementPC(false)
+		break
+
+	// OR
+	case OP_OR:
+		sys.registers[x] |= sys.registers[y]
+		if sys.quirks.VFReset {
+			sys.registers[0xF] = 0
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// AND
+	case OP_AND:
+		sys.registers[x] &= sys.registers[y]
+		if sys.quirks.VFReset {
+			sys.registers[0xF] = 0
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// XOR
+	case OP_XOR:
+		sys.registers[x] ^= sys.registers[y]
+		if sys.quirks.VFReset {
+			sys.registers[0xF] = 0
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// ADD
+	case OP_ADD_REG:
+		sum := sys.registers[x] + sys.registers[y]
+		if (sum > sys.registers[x]) == (sys.registers[y] > 0) {
+			sys.registers[0xF] = 0
+		} else {
+			sys.registers[0xF] = 1
+		}
+		sys.registers[x] = sum
+
+		sys.incrementPC(false)
+		break
+
+	// SUB
+	case OP_SUB:
+		if (sys.registers[x] > sys.registers[y]) {
+			sys.registers[0xF] = 1
+		} else {
+			sys.registers[0xF] = 0
+		}
+
+		sys.registers[x] -= sys.registers[y]
+
+		sys.incrementPC(false)
+		break
+
+	// SHR - shifts Vy into Vx unless the shift quirk is set
+	case OP_SHR:
+		source := sys.registers[y]
+		if sys.quirks.Shift {
+			source = sys.registers[x]
+		}
+
+		sys.registers[x] = source >> 1
+		sys.registers[0xF] = source & 0x1
+
+		sys.incrementPC(false)
+		break
+
+	// SUBN
+	case OP_SUBN:
+		if (sys.registers[y] > sys.registers[x]) {
+			sys.registers[0xF] = 1
+		} else {
+			sys.registers[0xF] = 0
+		}
+
+		sys.registers[x] = sys.registers[y] - sys.registers[x]
+
+		sys.incrementPC(false)
+		break
+
+	// SHL - shifts Vy into Vx unless the shift quirk is set
+	case OP_SHL:
+		source := sys.registers[y]
+		if sys.quirks.Shift {
+			source = sys.registers[x]
+		}
+
+		sys.registers[x] = source << 1
+		sys.registers[0xF] = (source >> 7) & 0x1
+
+		sys.incrementPC(false)
+		break
+
+	// SNE
+	case OP_SNE_REG:
+		if sys.registers[x] != sys.registers[y] {
+			sys.incrementPC(true)
+		} else {
+			sys.incrementPC(false)
+		}
+		break
+
+	// LD
+	case OP_LD_I:
+		sys.iregister = nnn
+
+		sys.incrementPC(false)
+		break
+
+	// JMP
+	case OP_JMP_V0:
+		if sys.quirks.Jump {
+			sys.programCounter = nnn + uint16(sys.registers[x])
+		} else {
+			sys.programCounter = nnn + uint16(sys.registers[0x0])
+		}
+		break
+
+	// RND
+	case OP_RND:
+		sys.registers[x] = kk & byte(sys.random.Intn(256))
+
+		sys.incrementPC(false)
+		break
+
+	// DRW - on SUPER-CHIP a height of 0 draws a 16x16 sprite. On XO-CHIP the
+	// sprite is drawn to every selected plane in turn, with the data for each
+	// plane following the previous one.
+	case OP_DRW:
+		if sys.quirks.DisplayWait && sys.frameDrawn {
+			// try again in the next frame
+			break
+		}
+
+		sys.registers[0xF] = 0
+		height := uint16(len(sys.display))
+		width := uint16(len(sys.display[0]))
+		startX := uint16(sys.registers[x]) % width
+		startY := uint16(sys.registers[y]) % height
+
+		rows := n
+		columns := uint16(8)
+		if n == 0 && sys.variant >= VARIANT_SCHIP {
+			rows = 16
+			columns = 16
+		}
+
+		address := int(sys.iregister)
+		for plane := uint8(0); plane < PLANE_COUNT; plane++ {
+			mask := byte(1) << plane
+			if sys.planes & mask == 0 {
+				continue
+			}
+
+			for yOffset := uint16(0); yOffset < rows; yOffset++ {
+				yAdjusted := startY + yOffset
+				if yAdjusted >= height {
+					if sys.quirks.Clipping {
+						break
+					}
+					yAdjusted %= height
+				}
+
+				var toDraw uint16
+				if columns == 16 {
+					high, err := sys.readMemory(address + int(yOffset) * 2)
+					if err != nil {
+						return err
+					}
+					low, err := sys.readMemory(address + int(yOffset) * 2 + 1)
+					if err != nil {
+						return err
+					}
+					toDraw = (uint16(high) << 8) | uint16(low)
+				} else {
+					row, err := sys.readMemory(address + int(yOffset))
+					if err != nil {
+						return err
+					}
+					toDraw = uint16(row) << 8
+				}
+				toDrawBits, err := bits(toDraw)
+				if err != nil {
+					return err
+				}
+
+				for xOffset := uint16(0); xOffset < columns; xOffset++ {
+					if !toDrawBits[xOffset] {
+						continue
+					}
+
+					xAdjusted := startX + xOffset
+					if xAdjusted >= width {
+						if sys.quirks.Clipping {
+							break
+						}
+						xAdjusted %= width
+					}
+
+					if sys.display[yAdjusted][xAdjusted] & mask != 0 {
+						sys.registers[0xF] = 1
+					}
+					sys.setPixel(xAdjusted, yAdjusted, sys.display[yAdjusted][xAdjusted] ^ mask)
+				}
+			}
+
+			address += int(rows * columns / 8)
+		}
+		sys.frameDrawn = true
+
+		err := sys.screen.Present(sys.display)
+		if err != nil {
+			return err
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// SKP
+	case OP_SKP:
+		key, err := sys.key(x)
+		if err != nil {
+			return err
+		}
+
+		if sys.keys[key] {
+			sys.incrementPC(true)
+
+			sys.keys[key] = false
+		} else {
+			sys.incrementPC(false)
+		}
+		break
+
+	// SKNP
+	case OP_SKNP:
+		key, err := sys.key(x)
+		if err != nil {
+			return err
+		}
+
+		if sys.keys[key] {
+			sys.incrementPC(false)
+
+			sys.keys[key] = false
+		} else {
+			sys.incrementPC(true)
+		}
+		break
+
+	// LONG - set I to the 16 bit address in the next word
+	case OP_LD_I_LONG:
+		sys.iregister = inst.Long
+
+		sys.incrementPC(false)
+		sys.incrementPC(false)
+		break
+
+	// PLANE - select the planes to draw to
+	case OP_PLANE:
+		sys.planes = x & 0x3
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Load delay timer value into vx
+	case OP_LD_VX_DT:
+		sys.registers[x] = sys.delayTimer
+
+		sys.incrementPC(false)
+		break
+
+	// LD - load from input, the instruction repeats until a key is pressed
+	case OP_LD_VX_K:
+		if !sys.waitingForKey {
+			sys.waitingForKey = true
+			sys.pressedKey = -1
+			break
+		}
+
+		if sys.pressedKey < 0 {
+			break
+		}
+
+		sys.registers[x] = byte(sys.pressedKey)
+		sys.waitingForKey = false
+		sys.pressedKey = -1
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Set delay timer
+	case OP_LD_DT_VX:
+		sys.delayTimer = sys.registers[x]
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Set sound timer
+	case OP_LD_ST_VX:
+		sys.soundTimer = sys.registers[x]
+
+		sys.incrementPC(false)
+		break
+
+	// ADD - I and Vx
+	case OP_ADD_I_VX:
+		sys.iregister += uint16(sys.registers[x])
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Set I to the value of the location of the sprite
+	case OP_LD_F_VX:
+		sys.iregister = FONT_START + uint16(sys.registers[x]) * 5
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Store BCD representation in to I, I+1, I+2
+	case OP_LD_B_VX:
+		digits := []byte{sys.registers[x] / 100, (sys.registers[x] / 10) % 10, (sys.registers[x] % 100) % 10}
+		for i, digit := range digits {
+			err := sys.writeMemory(int(sys.iregister) + i, digit)
+			if err != nil {
+				return err
+			}
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LD - store registers in memory
+	case OP_STORE:
+		for i := uint8(0); i <= x; i++ {
+			err := sys.writeMemory(int(sys.iregister) + int(i), sys.registers[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		if sys.quirks.LoadStore {
+			sys.iregister += uint16(x) + 1
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LD - load register from memory
+	case OP_LOAD:
+		for i := uint8(0); i <= x; i++ {
+			value, err := sys.readMemory(int(sys.iregister) + int(i))
+			if err != nil {
+				return err
+			}
+			sys.registers[i] = value
+		}
+
+		if sys.quirks.LoadStore {
+			sys.iregister += uint16(x) + 1
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LD - Set I to the location of the 8x10 sprite for the digit in Vx
+	case OP_LD_HF_VX:
+		sys.iregister = BIG_FONT_START + uint16(sys.registers[x] & 0xF) * 10
+
+		sys.incrementPC(false)
+		break
+
+	// LD - store registers in the user flags
+	case OP_LD_R_VX:
+		sys.recordRPLFlags()
+		for i := uint8(0); i <= x; i++ {
+			sys.rplFlags[i] = sys.registers[i]
+		}
+
+		sys.incrementPC(false)
+		break
+
+	// LD - load registers from the user flags
+	case OP_LD_VX_R:
+		for i := uint8(0); i <= x; i++ {
+			sys.registers[i] = sys.rplFlags[i]
+		}
+
+		sys.incrementPC(false)
+		break
+
+	default:
+		return sys.invalidOpcode()
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package chip8
+
+
+import (
+	"testing"
+)
+
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		memory []byte
+		op Op
+		text string
+		size uint16
+		variant Variant
+	}{
+		{[]byte{0x00, 0xE0}, OP_CLS, "CLS", 2, VARIANT_CHIP8},
+		{[]byte{0x00, 0xEE}, OP_RET, "RET", 2, VARIANT_CHIP8},
+		{[]byte{0x01, 0x23}, OP_SYS, "SYS 0x123", 2, VARIANT_CHIP8},
+		{[]byte{0x00, 0xC3}, OP_SCD, "SCD 3", 2, VARIANT_SCHIP},
+		{[]byte{0x00, 0xD3}, OP_SCU, "SCU 3", 2, VARIANT_XOCHIP},
+		{[]byte{0x00, 0xFD}, OP_EXIT, "EXIT", 2, VARIANT_SCHIP},
+		{[]byte{0x1A, 0xBC}, OP_JMP, "JMP 0xABC", 2, VARIANT_CHIP8},
+		{[]byte{0x3A, 0x42}, OP_SE_BYTE, "SE VA, 0x42", 2, VARIANT_CHIP8},
+		{[]byte{0x51, 0x20}, OP_SE_REG, "SE V1, V2", 2, VARIANT_CHIP8},
+		{[]byte{0x51, 0x22}, OP_SAVE, "SAVE V1, V2", 2, VARIANT_XOCHIP},
+		{[]byte{0x81, 0x2E}, OP_SHL, "SHL V1, V2", 2, VARIANT_CHIP8},
+		{[]byte{0xB2, 0x00}, OP_JMP_V0, "JMP V0, 0x200", 2, VARIANT_CHIP8},
+		{[]byte{0xD1, 0x25}, OP_DRW, "DRW V1, V2, 5", 2, VARIANT_CHIP8},
+		{[]byte{0xE3, 0x9E}, OP_SKP, "SKP V3", 2, VARIANT_CHIP8},
+		{[]byte{0xF0, 0x00, 0xBE, 0xEF}, OP_LD_I_LONG, "LONG I, 0xBEEF", 4, VARIANT_XOCHIP},
+		{[]byte{0xF2, 0x01}, OP_PLANE, "PLANE 2", 2, VARIANT_XOCHIP},
+		{[]byte{0xF5, 0x55}, OP_STORE, "LD [I], V5", 2, VARIANT_CHIP8},
+		{[]byte{0xF5, 0x85}, OP_LD_VX_R, "LD V5, R", 2, VARIANT_SCHIP},
+		{[]byte{0x5A, 0xB9}, OP_INVALID, "dw 0x5AB9", 2, VARIANT_CHIP8},
+		{[]byte{0xE1, 0x23}, OP_INVALID, "dw 0xE123", 2, VARIANT_CHIP8},
+		// the second word is past the end, which reads as 0
+		{[]byte{0xF0, 0x00}, OP_LD_I_LONG, "LONG I, 0x0000", 4, VARIANT_XOCHIP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			inst := DecodeAt(test.memory, 0)
+
+			if inst.Op != test.op || inst.String() != test.text {
+				t.Errorf("Decoded %X as %d %q, expected %d %q", test.memory, inst.Op, inst.String(), test.op, test.text)
+			}
+			if inst.Size() != test.size {
+				t.Errorf("Size is %d, expected %d", inst.Size(), test.size)
+			}
+			if inst.Variant() != test.variant {
+				t.Errorf("Variant is %s, expected %s", inst.Variant(), test.variant)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package chip8
+
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+
+// bytes per db line
+const LISTING_DATA_WIDTH = 8
+
+// label prefixes by the way an address is referenced, in order of precedence
+var LABEL_PREFIXES = map[Op]string{
+	OP_CALL: "sub",
+	OP_JMP: "label",
+	OP_JMP_V0: "table",
+	OP_LD_I: "data",
+	OP_LD_I_LONG: "data",
+}
+
+var LABEL_PRECEDENCE = []Op{OP_CALL, OP_JMP, OP_JMP_V0, OP_LD_I, OP_LD_I_LONG}
+
+
+// listingItem is an instruction, or a run of data bytes of one kind when
+// data is set
+type listingItem struct {
+	address int
+	inst Instruction
+	data []byte
+	kind ByteKind
+}
+
+
+// Listing is a ROM disassembled to assembler source that assembles back to
+// the same bytes. Only instructions reached from the start of the ROM are
+// disassembled, everything else is written as db. Referenced addresses get
+// labels.
+type Listing struct {
+	rom []byte
+	flow *Flow
+	items []listingItem
+	labels map[int]string
+}
+
+
+func NewListing(rom []byte, variant Variant) *Listing {
+	listing := new(Listing)
+	listing.rom = rom
+	listing.flow = FollowFlow(rom, variant)
+	listing.labels = make(map[int]string)
+
+	listing.split()
+	listing.label()
+
+	return listing
+}
+
+
+// split turns the ROM into instructions and runs of data of the same kind
+func (listing *Listing) split() {
+	for offset := 0; offset < len(listing.rom); {
+		address := PC_START + offset
+		kind := listing.flow.Kinds[offset]
+
+		if kind == BYTE_CODE {
+			inst := listing.flow.Instructions[address]
+			listing.items = append(listing.items, listingItem{address: address, inst: inst, kind: kind})
+			offset += int(inst.Size())
+			continue
+		}
+
+		start := offset
+		for offset < len(listing.rom) && listing.flow.Kinds[offset] == kind {
+			offset++
+		}
+		listing.items = append(listing.items, listingItem{address: address, data: listing.rom[start:offset], kind: kind})
+	}
+}
+
+// label names the addresses instructions refer to, as long as a label can be
+// put there, which is not the case in the middle of an instruction
+func (listing *Listing) label() {
+	boundaries := map[int]bool{PC_START + len(listing.rom): true}
+	for _, item := range listing.items {
+		if item.data == nil {
+			boundaries[item.address] = true
+			continue
+		}
+		for i := range item.data {
+			boundaries[item.address + i] = true
+		}
+	}
+
+	references := make(map[int]map[Op]bool)
+	for _, item := range listing.items {
+		if item.data != nil || LABEL_PREFIXES[item.inst.Op] == "" {
+			continue
+		}
+
+		target, _ := item.target()
+		if !boundaries[target] {
+			continue
+		}
+		if references[target] == nil {
+			references[target] = make(map[Op]bool)
+		}
+		references[target][item.inst.Op] = true
+	}
+
+	for address, ops := range references {
+		for _, op := range LABEL_PRECEDENCE {
+			if ops[op] {
+				listing.labels[address] = fmt.Sprintf("%s_%03X", LABEL_PREFIXES[op], address)
+				break
+			}
+		}
+	}
+}
+
+// target is the address an instruction refers to and the index of the
+// operand holding it
+func (item listingItem) target() (int, int) {
+	for i, operand := range item.inst.spec.operands {
+		switch operand {
+		case "nnn":
+			return int(item.inst.Address), i
+		case "nnnn":
+			return int(item.inst.Long), i
+		}
+	}
+
+	return -1, -1
+}
+
+// Labels are the generated labels by address
+func (listing *Listing) Labels() map[int]string {
+	return listing.labels
+}
+
+// Flow is the control flow the listing was made from
+func (listing *Listing) Flow() *Flow {
+	return listing.flow
+}
+
+// Write prints the listing, every line is commented with its address
+func (listing *Listing) Write(out io.Writer) error {
+	writer := bufio.NewWriter(out)
+
+	for _, item := range listing.items {
+		if item.data != nil {
+			listing.writeData(writer, item)
+			continue
+		}
+
+		listing.writeLabel(writer, item.address)
+
+		operands := item.inst.Operands()
+		if target, index := item.target(); index >= 0 && listing.labels[target] != "" {
+			operands[index] = listing.labels[target]
+		}
+		text := item.inst.Mnemonic()
+		if len(operands) > 0 {
+			text += " " + strings.Join(operands, ", ")
+		}
+		fmt.Fprintf(writer, "    %-24s ; %03X\n", text, item.address)
+	}
+
+	listing.writeLabel(writer, PC_START + len(listing.rom))
+
+	return writer.Flush()
+}
+
+// writeData prints data as db lines, which are split at labels. Sprites get
+// a line per row in binary.
+func (listing *Listing) writeData(writer *bufio.Writer, item listingItem) {
+	width := LISTING_DATA_WIDTH
+	format := "0x%02X"
+	if item.kind == BYTE_SPRITE {
+		width = 1
+		format = "0b%08b"
+	}
+	if item.kind == BYTE_UNREACHABLE {
+		fmt.Fprintf(writer, "    ; unreachable %03X to %03X\n", item.address, item.address + len(item.data) - 1)
+	}
+
+	for start := 0; start < len(item.data); {
+		listing.writeLabel(writer, item.address + start)
+
+		end := start + 1
+		for end < len(item.data) && end - start < width && listing.labels[item.address + end] == "" {
+			end++
+		}
+
+		values := make([]string, end - start)
+		for i, b := range item.data[start:end] {
+			values[i] = fmt.Sprintf(format, b)
+		}
+		fmt.Fprintf(writer, "    %-24s ; %03X\n", "db " + strings.Join(values, ", "), item.address + start)
+
+		start = end
+	}
+}
+
+func (listing *Listing) writeLabel(writer *bufio.Writer, address int) {
+	if name := listing.labels[address]; name != "" {
+		fmt.Fprintf(writer, "%s:\n", name)
+	}
+}
@@ -0,0 +1,76 @@
+package chip8
+
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+
+// reassemble writes the listing of rom to a file and assembles it again
+func reassemble(t *testing.T, rom []byte, variant Variant) []byte {
+	t.Helper()
+
+	file, err := ioutil.TempFile("", "listing*.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	err = NewListing(rom, variant).Write(file)
+	file.Close()
+	if err != nil {
+		t.Fatalf("Error writing listing: %v", err)
+	}
+
+	assembled, _, err := Assemble(file.Name(), variant)
+	if err != nil {
+		source, _ := ioutil.ReadFile(file.Name())
+		t.Fatalf("Error assembling listing: %v\n%s", err, source)
+	}
+
+	return assembled
+}
+
+
+func TestListingReassembles(t *testing.T) {
+	bcTest, err := ioutil.ReadFile("../tests/roms/BC_test.ch8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// random bytes with code at the start, so there are instructions, data
+	// and unreachable bytes, and an odd length
+	random := rand.New(rand.NewSource(1))
+	noise := make([]byte, 301)
+	random.Read(noise)
+
+	tests := []struct {
+		name string
+		rom []byte
+		variant Variant
+	}{
+		{"BC_test", bcTest, VARIANT_CHIP8},
+		{"jump into an instruction", []byte{0x12, 0x03, 0x60, 0x12, 0x06}, VARIANT_CHIP8},
+		{"sprite and table", []byte{
+			0xA2, 0x0C, 0xD0, 0x15, 0x60, 0x02, 0xB2, 0x0E,
+			0x12, 0x00, 0x00, 0x00, 0xF0, 0x90, 0x12, 0x00,
+			0x12, 0x02,
+		}, VARIANT_CHIP8},
+		{"noise chip8", append([]byte{0x22, 0x06, 0x12, 0x00, 0x00, 0x00, 0x00, 0xEE}, noise...), VARIANT_CHIP8},
+		{"noise schip", append([]byte{0x00, 0xFF, 0x12, 0x04, 0x00, 0xFD}, noise...), VARIANT_SCHIP},
+		{"noise xochip", append([]byte{0xF0, 0x00, 0x02, 0x0A, 0xF1, 0x65, 0x00, 0xFD, 0x00, 0x00}, noise...), VARIANT_XOCHIP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assembled := reassemble(t, test.rom, test.variant)
+			if !bytes.Equal(assembled, test.rom) {
+				t.Errorf("Reassembled %d bytes differ from the %d of the ROM", len(assembled), len(test.rom))
+			}
+		})
+	}
+}
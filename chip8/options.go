@@ -0,0 +1,103 @@
+package chip8
+
+
+// Option configures a System in NewSystem
+type Option func(*System)
+
+
+// WithClockspeed sets how many instructions are executed per second, 0
+// keeps the default
+func WithClockspeed(clockspeed uint64) Option {
+	return func(sys *System) {
+		if clockspeed > 0 {
+			sys.clockspeed = clockspeed
+		}
+	}
+}
+
+// WithDebug enables debug mode
+func WithDebug(debug bool) Option {
+	return func(sys *System) {
+		sys.debug = debug
+	}
+}
+
+// WithDisplay attaches a frontend to show the framebuffer on
+func WithDisplay(screen Display) Option {
+	return func(sys *System) {
+		sys.screen = screen
+	}
+}
+
+// WithAudio attaches a frontend to play the tone of the sound timer on
+func WithAudio(audio Audio) Option {
+	return func(sys *System) {
+		sys.audio = audio
+	}
+}
+
+// WithInput attaches a source of key events
+func WithInput(input Input) Option {
+	return func(sys *System) {
+		sys.input = input
+	}
+}
+
+// WithVirtualClock drives the 60 Hz timers from the instruction count instead
+// of the wall clock, decrementing them every CyclesPerFrame instructions
+func WithVirtualClock(virtualClock bool) Option {
+	return func(sys *System) {
+		sys.virtualClock = virtualClock
+	}
+}
+
+// WithSeed seeds the random number source used by RND, running the same ROM
+// with the same seed and input gives the same result
+func WithSeed(seed int64) Option {
+	return func(sys *System) {
+		sys.seed = seed
+	}
+}
+
+// WithWrapMemory makes instructions wrap addresses outside of memory and keys
+// above 0xF around instead of failing with a MemoryFault or KeyFault
+func WithWrapMemory(wrap bool) Option {
+	return func(sys *System) {
+		sys.wrapMemory = wrap
+	}
+}
+
+// WithVariant selects the platform to emulate, which decides the instructions
+// that are available and the default quirks
+func WithVariant(variant Variant) Option {
+	return func(sys *System) {
+		sys.variant = variant
+	}
+}
+
+// WithQuirks overrides the quirks of the variant
+func WithQuirks(quirks Quirks) Option {
+	return func(sys *System) {
+		sys.quirks = quirks
+		sys.quirksSet = true
+	}
+}
+
+// WithRewind keeps an undo history of the executed instructions for StepBack,
+// taking up at most about budget bytes
+func WithRewind(budget int) Option {
+	return func(sys *System) {
+		if budget > 0 {
+			sys.rewind = newRewindBuffer(budget)
+		} else {
+			sys.rewind = nil
+		}
+	}
+}
+
+// WithTrace writes every executed instruction to tracer
+func WithTrace(tracer *Tracer) Option {
+	return func(sys *System) {
+		sys.tracer = tracer
+	}
+}
@@ -0,0 +1,122 @@
+package chip8
+
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+
+// Quirks are the behaviours that differ between CHIP-8 platforms, as ROMs
+// rely on the interpretation of the platform they were written for
+type Quirks struct {
+	// 8XY6 and 8XYE shift Vx in place instead of shifting Vy into Vx
+	Shift bool
+	// FX55 and FX65 leave I pointing after the last register instead of
+	// leaving it untouched
+	LoadStore bool
+	// BNNN jumps to NNN + Vx, where x is the highest nibble of NNN, instead of
+	// NNN + V0
+	Jump bool
+	// 8XY1, 8XY2 and 8XY3 reset VF to 0
+	VFReset bool
+	// DXYN clips sprites at the edges of the display instead of wrapping them
+	// around to the other side
+	Clipping bool
+	// DXYN waits for the next frame, so at most one sprite is drawn per frame
+	DisplayWait bool
+}
+
+
+var (
+	// the interpretation most CHIP-8 emulators and test ROMs use, which is
+	// what this emulator always did
+	QUIRKS_CHIP8 = Quirks{
+		Shift: true,
+		LoadStore: false,
+		Jump: false,
+		VFReset: false,
+		Clipping: false,
+		DisplayWait: false,
+	}
+
+	// the original COSMAC VIP interpreter
+	QUIRKS_VIP = Quirks{
+		Shift: false,
+		LoadStore: true,
+		Jump: false,
+		VFReset: true,
+		Clipping: true,
+		DisplayWait: true,
+	}
+
+	// CHIP-48 on the HP-48 calculators
+	QUIRKS_CHIP48 = Quirks{
+		Shift: true,
+		LoadStore: false,
+		Jump: true,
+		VFReset: false,
+		Clipping: true,
+		DisplayWait: false,
+	}
+
+	// SUPER-CHIP 1.1
+	QUIRKS_SCHIP = Quirks{
+		Shift: true,
+		LoadStore: false,
+		Jump: true,
+		VFReset: false,
+		Clipping: true,
+		DisplayWait: false,
+	}
+
+	// XO-CHIP as implemented by Octo
+	QUIRKS_XOCHIP = Quirks{
+		Shift: false,
+		LoadStore: true,
+		Jump: false,
+		VFReset: false,
+		Clipping: false,
+		DisplayWait: false,
+	}
+)
+
+
+// named quirk profiles, as used by --quirks
+var QUIRK_PRESETS = map[string]Quirks{
+	"chip8": QUIRKS_CHIP8,
+	"vip": QUIRKS_VIP,
+	"chip48": QUIRKS_CHIP48,
+	"schip": QUIRKS_SCHIP,
+	"xochip": QUIRKS_XOCHIP,
+}
+
+
+// DefaultQuirks is the profile of the platform a variant comes from
+func DefaultQuirks(variant Variant) Quirks {
+	switch variant {
+	case VARIANT_SCHIP:
+		return QUIRKS_SCHIP
+	case VARIANT_XOCHIP:
+		return QUIRKS_XOCHIP
+	default:
+		return QUIRKS_CHIP8
+	}
+}
+
+// ParseQuirks looks up a profile in QUIRK_PRESETS by name
+func ParseQuirks(name string) (Quirks, error) {
+	quirks, ok := QUIRK_PRESETS[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(QUIRK_PRESETS))
+		for presetName := range QUIRK_PRESETS {
+			names = append(names, presetName)
+		}
+		sort.Strings(names)
+
+		return Quirks{}, fmt.Errorf("Unknown quirks %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	return quirks, nil
+}
@@ -0,0 +1,78 @@
+package chip8
+
+
+import (
+	"testing"
+)
+
+
+func TestQuirks(t *testing.T) {
+	tests := []struct {
+		name string
+		// the quirk being tested, all others are off
+		quirks Quirks
+		rom []byte
+		steps int
+		// what the ROM leaves behind with and without the quirk
+		result func(sys *System) int
+		on int
+		off int
+	}{
+		// V1 = 0x04, V2 = 0x03, SHR V1, V2
+		{"8XY6 shifts VX", Quirks{Shift: true},
+			[]byte{0x61, 0x04, 0x62, 0x03, 0x81, 0x26}, 3,
+			func(sys *System) int { return int(sys.registers[1]) << 8 | int(sys.registers[0xF]) },
+			0x0200, 0x0101},
+		// I = 0x300, V0 = 0x01, LD [I], V1
+		{"FX55 moves I", Quirks{LoadStore: true},
+			[]byte{0xA3, 0x00, 0x60, 0x01, 0xF1, 0x55}, 3,
+			func(sys *System) int { return int(sys.iregister) },
+			0x302, 0x300},
+		// V0 = 0x02, V3 = 0x04, JP V0, 0x300
+		{"BNNN jumps with VX", Quirks{Jump: true},
+			[]byte{0x60, 0x02, 0x63, 0x04, 0xB3, 0x00}, 3,
+			func(sys *System) int { return int(sys.programCounter) },
+			0x304, 0x302},
+		// VF = 0x05, V1 = 0x01, V2 = 0x02, OR V1, V2
+		{"8XY1 resets VF", Quirks{VFReset: true},
+			[]byte{0x6F, 0x05, 0x61, 0x01, 0x62, 0x02, 0x81, 0x21}, 4,
+			func(sys *System) int { return int(sys.registers[1]) << 8 | int(sys.registers[0xF]) },
+			0x0300, 0x0305},
+		// I = 0x20A, V0 = 60, V1 = 0, DRW V0, V1, 1 with a sprite of 8 pixels
+		// that reaches past the right edge
+		{"DXYN clips", Quirks{Clipping: true},
+			[]byte{0xA2, 0x0A, 0x60, 0x3C, 0x61, 0x00, 0xD0, 0x11, 0x12, 0x08, 0xFF}, 4,
+			func(sys *System) int { return int(sys.display[0][0]) },
+			0, 1},
+		// I = 0x20C, V0 = 0, V1 = 0, DRW V0, V1, 1 twice in the same frame
+		{"DXYN waits for the next frame", Quirks{DisplayWait: true},
+			[]byte{0xA2, 0x0C, 0x60, 0x00, 0x61, 0x00, 0xD0, 0x11, 0xD0, 0x11, 0x12, 0x0A, 0xFF}, 5,
+			func(sys *System) int { return int(sys.programCounter) },
+			0x208, 0x20A},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, on := range []bool{true, false} {
+				quirks := Quirks{}
+				expected := test.off
+				if on {
+					quirks = test.quirks
+					expected = test.on
+				}
+
+				sys := newTestSystem(t, test.rom, WithQuirks(quirks))
+				for i := 0; i < test.steps; i++ {
+					_, err := sys.Step()
+					if err != nil {
+						t.Fatalf("Error stepping: %v", err)
+					}
+				}
+
+				if result := test.result(sys); result != expected {
+					t.Errorf("With the quirk %t got 0x%X, expected 0x%X", on, result, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package chip8
+
+// added to the state for every number drawn
+const SPLITMIX_GAMMA = 0x9E3779B97F4A7C15
+
+
+// countingSource is a SplitMix64 generator that counts the numbers drawn from
+// it. Its state moves by the same amount for every number, so the source
+// after any number of draws can be made without drawing them again, and the
+// whole source can be copied to restore it later.
+type countingSource struct {
+	state uint64
+	draws uint64
+}
+
+
+func newCountingSource(seed int64, draws uint64) *countingSource {
+	return &countingSource{
+		state: uint64(seed) + draws * SPLITMIX_GAMMA,
+		draws: draws,
+	}
+}
+
+
+func (source *countingSource) Int63() int64 {
+	source.draws++
+	source.state += SPLITMIX_GAMMA
+
+	value := source.state
+	value = (value ^ (value >> 30)) * 0xBF58476D1CE4E5B9
+	value = (value ^ (value >> 27)) * 0x94D049BB133111EB
+	value ^= value >> 31
+
+	return int64(value >> 1)
+}
+
+func (source *countingSource) Seed(seed int64) {
+	source.state = uint64(seed)
+	source.draws = 0
+}
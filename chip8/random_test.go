@@ -0,0 +1,44 @@
+package chip8
+
+
+import (
+	"testing"
+)
+
+
+func TestStepsWithSeedRepeatRND(t *testing.T) {
+	rom := []byte{0xC0, 0xFF, 0xC1, 0xFF, 0xC2, 0xFF, 0xC3, 0xFF}
+
+	registers := func(seed int64) Registers {
+		sys := newTestSystem(t, rom, WithSeed(seed))
+		for i := 0; i < 4; i++ {
+			_, err := sys.Step()
+			if err != nil {
+				t.Fatalf("Error stepping: %v", err)
+			}
+		}
+		return sys.Registers()
+	}
+
+	if registers(7) != registers(7) {
+		t.Errorf("RND differs between runs with the same seed")
+	}
+	if registers(7) == registers(8) {
+		t.Errorf("RND is the same for different seeds")
+	}
+}
+
+func TestCountingSourceSkipsDraws(t *testing.T) {
+	source := newCountingSource(-5, 0)
+	for i := 0; i < 100; i++ {
+		source.Int63()
+	}
+
+	skipped := newCountingSource(-5, 100)
+	if *skipped != *source {
+		t.Fatalf("Skipping 100 draws gave %+v, drawing them %+v", *skipped, *source)
+	}
+	if skipped.Int63() != source.Int63() {
+		t.Errorf("Sources differ after skipping draws")
+	}
+}
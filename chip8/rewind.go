@@ -0,0 +1,277 @@
+package chip8
+
+
+import (
+	"errors"
+)
+
+
+// ErrNoHistory is returned by StepBack when there is nothing left to rewind
+var ErrNoHistory = errors.New("No history to rewind")
+
+
+type memoryChange struct {
+	address uint16
+	value byte
+}
+
+type pixelChange struct {
+	x uint16
+	y uint16
+	value byte
+}
+
+
+// rewindEntry holds what is needed to undo a single instruction. The small
+// parts of the machine are copied whole, memory and pixels as the values
+// they had before they were changed.
+type rewindEntry struct {
+	registers [REGISTER_COUNT]byte
+	iregister uint16
+	programCounter uint16
+	opcode uint16
+	stack [STACK_SIZE]uint16
+	stackIndex uint
+	delayTimer byte
+	soundTimer byte
+	hires bool
+	planes byte
+	frameDrawn bool
+	keys [KEY_COUNT]bool
+	waitingForKey bool
+	pressedKey int
+	haltReason HaltReason
+	fault error
+	cycles uint64
+	random countingSource
+
+	memory []memoryChange
+	pixels []pixelChange
+	// set when the whole display changed, such as when scrolling
+	display [][]byte
+	// set when the user flags changed
+	rplFlags []byte
+}
+
+
+// rough number of bytes an entry takes up, for the memory budget
+func (entry *rewindEntry) size() int {
+	size := 128 + len(entry.memory) * 4 + len(entry.pixels) * 6 + len(entry.rplFlags)
+	for _, row := range entry.display {
+		size += len(row)
+	}
+
+	return size
+}
+
+
+// rewindBuffer is a ring buffer of the most recent entries which fit in the
+// budget, the oldest ones are dropped first
+type rewindBuffer struct {
+	entries []*rewindEntry
+	start int
+	count int
+	size int
+	budget int
+}
+
+
+func newRewindBuffer(budget int) *rewindBuffer {
+	buffer := new(rewindBuffer)
+	buffer.entries = make([]*rewindEntry, 64)
+	buffer.budget = budget
+	return buffer
+}
+
+
+func (buffer *rewindBuffer) push(entry *rewindEntry) {
+	size := entry.size()
+	if size > buffer.budget {
+		buffer.clear()
+		return
+	}
+
+	for buffer.count > 0 && buffer.size + size > buffer.budget {
+		oldest := buffer.entries[buffer.start]
+		buffer.entries[buffer.start] = nil
+		buffer.start = (buffer.start + 1) % len(buffer.entries)
+		buffer.count--
+		buffer.size -= oldest.size()
+	}
+
+	if buffer.count == len(buffer.entries) {
+		grown := make([]*rewindEntry, len(buffer.entries) * 2)
+		for i := 0; i < buffer.count; i++ {
+			grown[i] = buffer.entries[(buffer.start + i) % len(buffer.entries)]
+		}
+		buffer.entries = grown
+		buffer.start = 0
+	}
+
+	buffer.entries[(buffer.start + buffer.count) % len(buffer.entries)] = entry
+	buffer.count++
+	buffer.size += size
+}
+
+// pop removes the newest entry, nil if there is none
+func (buffer *rewindBuffer) pop() *rewindEntry {
+	if buffer.count == 0 {
+		return nil
+	}
+
+	index := (buffer.start + buffer.count - 1) % len(buffer.entries)
+	entry := buffer.entries[index]
+	buffer.entries[index] = nil
+	buffer.count--
+	buffer.size -= entry.size()
+
+	return entry
+}
+
+func (buffer *rewindBuffer) clear() {
+	for i := range buffer.entries {
+		buffer.entries[i] = nil
+	}
+	buffer.start = 0
+	buffer.count = 0
+	buffer.size = 0
+}
+
+
+// start recording the changes made by the next instruction
+func (sys *System) beginRecording() {
+	if sys.rewind == nil {
+		return
+	}
+
+	entry := new(rewindEntry)
+	copy(entry.registers[:], sys.registers)
+	entry.iregister = sys.iregister
+	entry.programCounter = sys.programCounter
+	entry.opcode = sys.opcode
+	copy(entry.stack[:], sys.stack.memory)
+	entry.stackIndex = sys.stack.index
+	entry.delayTimer = sys.delayTimer
+	entry.soundTimer = sys.soundTimer
+	entry.hires = sys.hires
+	entry.planes = sys.planes
+	entry.frameDrawn = sys.frameDrawn
+	copy(entry.keys[:], sys.keys)
+	entry.waitingForKey = sys.waitingForKey
+	entry.pressedKey = sys.pressedKey
+	entry.haltReason = sys.haltReason
+	entry.fault = sys.fault
+	entry.cycles = sys.cycles
+	entry.random = *sys.randomSource
+
+	sys.recording = entry
+}
+
+func (sys *System) endRecording() {
+	if sys.recording == nil {
+		return
+	}
+
+	sys.rewind.push(sys.recording)
+	sys.recording = nil
+}
+
+// remember the whole display before it is replaced or moved
+func (sys *System) recordDisplay() {
+	if sys.recording == nil || sys.recording.display != nil {
+		return
+	}
+
+	sys.recording.display = make([][]byte, len(sys.display))
+	for i, row := range sys.display {
+		sys.recording.display[i] = make([]byte, len(row))
+		copy(sys.recording.display[i], row)
+	}
+}
+
+// remember the user flags before they are changed
+func (sys *System) recordRPLFlags() {
+	if sys.recording == nil || sys.recording.rplFlags != nil {
+		return
+	}
+
+	sys.recording.rplFlags = make([]byte, len(sys.rplFlags))
+	copy(sys.recording.rplFlags, sys.rplFlags)
+}
+
+func (sys *System) setPixel(x uint16, y uint16, value byte) {
+	if sys.recording != nil && sys.recording.display == nil {
+		sys.recording.pixels = append(sys.recording.pixels, pixelChange{x, y, sys.display[y][x]})
+	}
+
+	sys.display[y][x] = value
+}
+
+
+// StepBack undoes the most recent instruction. Only instructions executed
+// while rewinding was enabled with WithRewind can be undone.
+func (sys *System) StepBack() error {
+	if sys.rewind == nil {
+		return ErrNoHistory
+	}
+
+	entry := sys.rewind.pop()
+	if entry == nil {
+		return ErrNoHistory
+	}
+
+	copy(sys.registers, entry.registers[:])
+	sys.iregister = entry.iregister
+	sys.programCounter = entry.programCounter
+	sys.opcode = entry.opcode
+	copy(sys.stack.memory, entry.stack[:])
+	sys.stack.index = entry.stackIndex
+	sys.delayTimer = entry.delayTimer
+	sys.soundTimer = entry.soundTimer
+	sys.hires = entry.hires
+	sys.planes = entry.planes
+	sys.frameDrawn = entry.frameDrawn
+	copy(sys.keys, entry.keys[:])
+	sys.waitingForKey = entry.waitingForKey
+	sys.pressedKey = entry.pressedKey
+	sys.haltReason = entry.haltReason
+	sys.fault = entry.fault
+	sys.cycles = entry.cycles
+
+	*sys.randomSource = entry.random
+
+	for i := len(entry.memory) - 1; i >= 0; i-- {
+		sys.memory[entry.memory[i].address] = entry.memory[i].value
+	}
+
+	if entry.rplFlags != nil {
+		copy(sys.rplFlags, entry.rplFlags)
+	}
+
+	if entry.display != nil {
+		resized := len(entry.display) != len(sys.display) || len(entry.display[0]) != len(sys.display[0])
+		sys.display = entry.display
+		if resized {
+			err := sys.screen.Resize(len(sys.display[0]), len(sys.display))
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for i := len(entry.pixels) - 1; i >= 0; i-- {
+			pixel := entry.pixels[i]
+			sys.display[pixel.y][pixel.x] = pixel.value
+		}
+	}
+
+	return sys.screen.Present(sys.display)
+}
+
+// History is the number of instructions StepBack can undo
+func (sys *System) History() int {
+	if sys.rewind == nil {
+		return 0
+	}
+
+	return sys.rewind.count
+}
@@ -0,0 +1,84 @@
+package chip8
+
+
+import (
+	"bytes"
+	"testing"
+)
+
+
+func TestStepBack(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+		variant Variant
+	}{
+		// calls, stores to memory, random numbers and the timers, which tick
+		// with every instruction at 60 Hz
+		{"chip8", []byte{
+			0x60, 0x10, 0xF0, 0x15, 0xF0, 0x18, 0xC1, 0xFF,
+			0x22, 0x10, 0xA3, 0x00, 0xF1, 0x55, 0x12, 0x06,
+			0xF2, 0x07, 0x00, 0xEE,
+		}, VARIANT_CHIP8},
+		// switching resolution, scrolling and drawing
+		{"schip", []byte{
+			0x00, 0xFF, 0xA2, 0x0C, 0xD0, 0x00, 0x00, 0xC4,
+			0x70, 0x08, 0x12, 0x04,
+		}, VARIANT_SCHIP},
+		// a long load, drawing to both planes and an exit
+		{"xochip", []byte{
+			0xF0, 0x00, 0x02, 0x0E, 0xF3, 0x01, 0xD0, 0x12,
+			0x00, 0xE0, 0x00, 0xFD, 0x00, 0x00, 0xFF, 0x81,
+			0x42, 0x24,
+		}, VARIANT_XOCHIP},
+		{"fault", []byte{0x60, 0x01, 0x00, 0xEE}, VARIANT_CHIP8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newTestSystem(t, test.rom, WithVariant(test.variant), WithClockspeed(60), WithVirtualClock(true), WithRewind(1 << 20))
+
+			states := [][]byte{saveState(t, sys)}
+			for i := 0; i < 20 && !sys.Halted(); i++ {
+				sys.Step()
+				states = append(states, saveState(t, sys))
+			}
+
+			for i := len(states) - 2; i >= 0; i-- {
+				err := sys.StepBack()
+				if err != nil {
+					t.Fatalf("Error stepping back to step %d: %v", i, err)
+				}
+				if !bytes.Equal(saveState(t, sys), states[i]) {
+					t.Fatalf("Stepping back to step %d restored a different machine", i)
+				}
+			}
+
+			if sys.StepBack() != ErrNoHistory {
+				t.Errorf("Stepped back past the first instruction")
+			}
+		})
+	}
+}
+
+func TestStepBackRepeatsRND(t *testing.T) {
+	rom := []byte{0xC0, 0xFF, 0x12, 0x00}
+	sys := newTestSystem(t, rom, WithSeed(3), WithRewind(1 << 20))
+
+	var drawn []byte
+	for i := 0; i < 1000; i++ {
+		sys.Step()
+		drawn = append(drawn, sys.Registers().V[0])
+		sys.Step()
+	}
+
+	for i := len(drawn) - 1; i >= 0; i-- {
+		sys.StepBack()
+		sys.StepBack()
+		sys.Step()
+		if sys.Registers().V[0] != drawn[i] {
+			t.Fatalf("RND %d drew 0x%02X after stepping back, expected 0x%02X", i, sys.Registers().V[0], drawn[i])
+		}
+		sys.StepBack()
+	}
+}
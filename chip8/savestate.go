@@ -0,0 +1,411 @@
+package chip8
+
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+
+const (
+	STATE_MAGIC = "C8ST"
+	STATE_VERSION = 2
+)
+
+// kinds of faults a save state can hold, errors that are not one of the
+// typed faults are kept as their message
+const (
+	STATE_FAULT_NONE uint8 = iota
+	STATE_FAULT_INVALID_OPCODE
+	STATE_FAULT_STACK_OVERFLOW
+	STATE_FAULT_STACK_UNDERFLOW
+	STATE_FAULT_MEMORY
+	STATE_FAULT_KEY
+	STATE_FAULT_OTHER
+)
+
+
+/* Save state format, all numbers are big endian
+ * magic "C8ST", version uint16
+ * variant uint8, quirks 6 x uint8
+ * memory length uint32, memory
+ * registers 16 bytes, I uint16, PC uint16, opcode uint16
+ * stack capacity uint16, stack index uint16, stack capacity x uint16
+ * delay timer uint8, sound timer uint8
+ * hires uint8, planes uint8, frame drawn uint8
+ * display height uint16, display width uint16, display
+ * keys 16 x uint8, waiting for key uint8, pressed key int8
+ * user flags 16 bytes
+ * halt reason uint8
+ * fault kind uint8, PC uint16, opcode uint16, address int32, write uint8,
+ *     key uint8, message length uint16, message
+ * cycles uint64
+ * seed int64, numbers drawn from the random source uint64
+ */
+
+
+// stateWriter writes fields until the first error
+type stateWriter struct {
+	out io.Writer
+	err error
+}
+
+
+func (writer *stateWriter) write(value interface{}) {
+	if writer.err != nil {
+		return
+	}
+
+	writer.err = binary.Write(writer.out, binary.BigEndian, value)
+}
+
+
+// stateReader reads fields until the first error
+type stateReader struct {
+	in io.Reader
+	err error
+}
+
+
+func (reader *stateReader) read(value interface{}) {
+	if reader.err != nil {
+		return
+	}
+
+	reader.err = binary.Read(reader.in, binary.BigEndian, value)
+}
+
+
+// writeFault writes the kind of a fault followed by the fields of every kind
+func (writer *stateWriter) writeFault(err error) {
+	kind := STATE_FAULT_NONE
+	var pc, opcode uint16
+	var address int32
+	var write bool
+	var key uint8
+	message := ""
+
+	switch fault := err.(type) {
+	case nil:
+		break
+
+	case *InvalidOpcode:
+		kind, pc, opcode = STATE_FAULT_INVALID_OPCODE, fault.PC, fault.Opcode
+		break
+
+	case *StackOverflow:
+		kind, pc, opcode = STATE_FAULT_STACK_OVERFLOW, fault.PC, fault.Opcode
+		break
+
+	case *StackUnderflow:
+		kind, pc, opcode = STATE_FAULT_STACK_UNDERFLOW, fault.PC, fault.Opcode
+		break
+
+	case *MemoryFault:
+		kind, pc, opcode = STATE_FAULT_MEMORY, fault.PC, fault.Opcode
+		address = int32(fault.Address)
+		write = fault.Write
+		break
+
+	case *KeyFault:
+		kind, pc, opcode = STATE_FAULT_KEY, fault.PC, fault.Opcode
+		key = fault.Key
+		break
+
+	default:
+		kind = STATE_FAULT_OTHER
+		message = err.Error()
+		if len(message) > 0xFFFF {
+			message = message[:0xFFFF]
+		}
+		break
+	}
+
+	writer.write(kind)
+	writer.write(pc)
+	writer.write(opcode)
+	writer.write(address)
+	writer.write(write)
+	writer.write(key)
+	writer.write(uint16(len(message)))
+	writer.write([]byte(message))
+}
+
+// readFault reads a fault written by writeFault
+func (reader *stateReader) readFault() error {
+	var kind uint8
+	var pc, opcode uint16
+	var address int32
+	var write bool
+	var key uint8
+	var length uint16
+
+	reader.read(&kind)
+	reader.read(&pc)
+	reader.read(&opcode)
+	reader.read(&address)
+	reader.read(&write)
+	reader.read(&key)
+	reader.read(&length)
+	message := make([]byte, length)
+	reader.read(message)
+
+	if reader.err != nil {
+		return nil
+	}
+
+	switch kind {
+	case STATE_FAULT_NONE:
+		return nil
+	case STATE_FAULT_INVALID_OPCODE:
+		return &InvalidOpcode{PC: pc, Opcode: opcode}
+	case STATE_FAULT_STACK_OVERFLOW:
+		return &StackOverflow{PC: pc, Opcode: opcode}
+	case STATE_FAULT_STACK_UNDERFLOW:
+		return &StackUnderflow{PC: pc, Opcode: opcode}
+	case STATE_FAULT_MEMORY:
+		return &MemoryFault{PC: pc, Opcode: opcode, Address: int(address), Write: write}
+	case STATE_FAULT_KEY:
+		return &KeyFault{PC: pc, Opcode: opcode, Key: key}
+	case STATE_FAULT_OTHER:
+		return errors.New(string(message))
+	}
+
+	reader.err = fmt.Errorf("Unknown fault kind %d", kind)
+	return nil
+}
+
+
+// SaveState writes a snapshot of the whole machine. Attached frontends are
+// not part of it.
+func (sys *System) SaveState(out io.Writer) error {
+	writer := &stateWriter{out: out}
+
+	writer.write([]byte(STATE_MAGIC))
+	writer.write(uint16(STATE_VERSION))
+
+	writer.write(uint8(sys.variant))
+	writer.write([]bool{
+		sys.quirks.Shift,
+		sys.quirks.LoadStore,
+		sys.quirks.Jump,
+		sys.quirks.VFReset,
+		sys.quirks.Clipping,
+		sys.quirks.DisplayWait,
+	})
+
+	writer.write(uint32(len(sys.memory)))
+	writer.write(sys.memory)
+
+	writer.write(sys.registers)
+	writer.write(sys.iregister)
+	writer.write(sys.programCounter)
+	writer.write(sys.opcode)
+
+	writer.write(uint16(sys.stack.capacity))
+	writer.write(uint16(sys.stack.index))
+	writer.write(sys.stack.memory)
+
+	writer.write(sys.delayTimer)
+	writer.write(sys.soundTimer)
+
+	writer.write(sys.hires)
+	writer.write(sys.planes)
+	writer.write(sys.frameDrawn)
+
+	writer.write(uint16(len(sys.display)))
+	writer.write(uint16(len(sys.display[0])))
+	for _, row := range sys.display {
+		writer.write(row)
+	}
+
+	writer.write(sys.keys)
+	writer.write(sys.waitingForKey)
+	writer.write(int8(sys.pressedKey))
+
+	writer.write(sys.rplFlags)
+
+	writer.write(uint8(sys.haltReason))
+	writer.writeFault(sys.fault)
+	writer.write(sys.cycles)
+
+	writer.write(sys.seed)
+	writer.write(sys.randomSource.draws)
+
+	return writer.err
+}
+
+// LoadState restores a snapshot written by SaveState. The machine is only
+// changed if the whole snapshot could be read.
+func (sys *System) LoadState(in io.Reader) error {
+	reader := &stateReader{in: in}
+
+	magic := make([]byte, len(STATE_MAGIC))
+	reader.read(magic)
+	if reader.err == nil && string(magic) != STATE_MAGIC {
+		return errors.New("Not a save state")
+	}
+
+	var version uint16
+	reader.read(&version)
+	if reader.err == nil && version != STATE_VERSION {
+		return fmt.Errorf("Unsupported save state version %d", version)
+	}
+
+	var variant uint8
+	reader.read(&variant)
+	if _, ok := VARIANT_NAMES[Variant(variant)]; reader.err == nil && !ok {
+		return fmt.Errorf("Invalid variant %d in save state", variant)
+	}
+	quirks := make([]bool, 6)
+	reader.read(quirks)
+
+	var memorySize uint32
+	reader.read(&memorySize)
+	if reader.err == nil && int(memorySize) != Variant(variant).MemorySize() {
+		return fmt.Errorf("Invalid memory size %d for %s in save state", memorySize, Variant(variant))
+	}
+	memory := make([]byte, memorySize)
+	reader.read(memory)
+
+	registers := make([]byte, REGISTER_COUNT)
+	reader.read(registers)
+	var iregister, programCounter, opcode uint16
+	reader.read(&iregister)
+	reader.read(&programCounter)
+	reader.read(&opcode)
+
+	var stackCapacity, stackIndex uint16
+	reader.read(&stackCapacity)
+	reader.read(&stackIndex)
+	if reader.err == nil && (stackCapacity != STACK_SIZE || stackIndex > stackCapacity) {
+		return errors.New("Invalid stack in save state")
+	}
+	stack := newStack(uint(stackCapacity))
+	stack.index = uint(stackIndex)
+	reader.read(stack.memory)
+
+	var delayTimer, soundTimer byte
+	reader.read(&delayTimer)
+	reader.read(&soundTimer)
+
+	var hires, frameDrawn bool
+	var planes byte
+	reader.read(&hires)
+	reader.read(&planes)
+	reader.read(&frameDrawn)
+	if reader.err == nil && hires && Variant(variant) < VARIANT_SCHIP {
+		return fmt.Errorf("Invalid hi-res mode for %s in save state", Variant(variant))
+	}
+	if reader.err == nil && (planes >= 1 << PLANE_COUNT || (planes != 1 && Variant(variant) < VARIANT_XOCHIP)) {
+		return fmt.Errorf("Invalid planes 0x%X for %s in save state", planes, Variant(variant))
+	}
+
+	// the display has the size of the resolution it is in
+	var height, width uint16
+	reader.read(&height)
+	reader.read(&width)
+	validWidth, validHeight := DISPLAY_WIDTH, DISPLAY_HEIGHT
+	if hires {
+		validWidth, validHeight = HIRES_DISPLAY_WIDTH, HIRES_DISPLAY_HEIGHT
+	}
+	if reader.err == nil && (int(width) != validWidth || int(height) != validHeight) {
+		return fmt.Errorf("Invalid display size %dx%d in save state", width, height)
+	}
+	display := make([][]byte, height)
+	for i := range display {
+		display[i] = make([]byte, width)
+		reader.read(display[i])
+
+		for _, pixel := range display[i] {
+			if reader.err == nil && pixel >= 1 << PLANE_COUNT {
+				return fmt.Errorf("Invalid pixel 0x%X in save state", pixel)
+			}
+		}
+	}
+
+	keys := make([]bool, KEY_COUNT)
+	reader.read(keys)
+	var waitingForKey bool
+	var pressedKey int8
+	reader.read(&waitingForKey)
+	reader.read(&pressedKey)
+	// -1 when no key was pressed while waiting
+	if reader.err == nil && (pressedKey < -1 || int(pressedKey) >= KEY_COUNT) {
+		return fmt.Errorf("Invalid pressed key %d in save state", pressedKey)
+	}
+
+	rplFlags := make([]byte, RPL_COUNT)
+	reader.read(rplFlags)
+
+	var haltReason uint8
+	reader.read(&haltReason)
+	if _, ok := HALT_REASON_NAMES[HaltReason(haltReason)]; reader.err == nil && !ok {
+		return fmt.Errorf("Invalid halt reason %d in save state", haltReason)
+	}
+	fault := reader.readFault()
+	if reader.err == nil && (fault != nil) != (HaltReason(haltReason) == HALT_FAULT) {
+		return errors.New("Invalid fault in save state")
+	}
+
+	var cycles uint64
+	reader.read(&cycles)
+
+	// every RND draws one number
+	var seed int64
+	var draws uint64
+	reader.read(&seed)
+	reader.read(&draws)
+	if reader.err == nil && draws > cycles {
+		return fmt.Errorf("Invalid count of %d random numbers in save state", draws)
+	}
+
+	if reader.err != nil {
+		return fmt.Errorf("Invalid save state: %v", reader.err)
+	}
+
+	sys.variant = Variant(variant)
+	sys.quirks = Quirks{
+		Shift: quirks[0],
+		LoadStore: quirks[1],
+		Jump: quirks[2],
+		VFReset: quirks[3],
+		Clipping: quirks[4],
+		DisplayWait: quirks[5],
+	}
+	sys.quirksSet = true
+	sys.memory = memory
+	sys.registers = registers
+	sys.iregister = iregister
+	sys.programCounter = programCounter
+	sys.opcode = opcode
+	sys.stack = stack
+	sys.delayTimer = delayTimer
+	sys.soundTimer = soundTimer
+	sys.hires = hires
+	sys.planes = planes
+	sys.frameDrawn = frameDrawn
+	sys.display = display
+	sys.keys = keys
+	sys.waitingForKey = waitingForKey
+	sys.pressedKey = int(pressedKey)
+	sys.rplFlags = rplFlags
+	sys.haltReason = HaltReason(haltReason)
+	sys.fault = fault
+	sys.cycles = cycles
+	sys.setRandom(seed, draws)
+
+	// the history leads up to a different machine
+	if sys.rewind != nil {
+		sys.rewind.clear()
+	}
+
+	err := sys.screen.Resize(int(width), int(height))
+	if err != nil {
+		return err
+	}
+
+	return sys.screen.Present(sys.display)
+}
@@ -0,0 +1,128 @@
+package chip8
+
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+
+func TestSaveStateLoadState(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+		variant Variant
+		reason HaltReason
+	}{
+		// random numbers, a running delay timer and a sprite on the display
+		{"running", []byte{
+			0xC0, 0xFF, 0x64, 0x30, 0xF4, 0x15, 0xF0, 0x29,
+			0xD1, 0x25, 0x71, 0x01, 0x12, 0x00,
+		}, VARIANT_CHIP8, HALT_NONE},
+		{"hi-res", []byte{0x00, 0xFF, 0xA2, 0x08, 0xD0, 0x00, 0x12, 0x06}, VARIANT_SCHIP, HALT_NONE},
+		{"two planes", []byte{0xF3, 0x01, 0xA2, 0x08, 0xD0, 0x01, 0x12, 0x06, 0xFF, 0x81}, VARIANT_XOCHIP, HALT_NONE},
+		{"exited", []byte{0x60, 0x01, 0x00, 0xFD}, VARIANT_SCHIP, HALT_EXIT},
+		{"faulted", []byte{0x60, 0x01, 0x00, 0xEE}, VARIANT_CHIP8, HALT_FAULT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newTestSystem(t, test.rom, WithVariant(test.variant), WithSeed(3), WithVirtualClock(true))
+			sys.RunCycles(20)
+			if sys.HaltReason() != test.reason {
+				t.Fatalf("Halt reason is %s, expected %s", sys.HaltReason(), test.reason)
+			}
+			saved := saveState(t, sys)
+
+			// the loaded machine takes its variant and seed from the state, but
+			// the clock is not part of it
+			loaded := NewSystem(WithSeed(4), WithVirtualClock(true))
+			err := loaded.LoadState(bytes.NewReader(saved))
+			if err != nil {
+				t.Fatalf("Error loading state: %v", err)
+			}
+
+			if !bytes.Equal(saveState(t, loaded), saved) {
+				t.Errorf("Loaded machine differs from the saved one")
+			}
+			if loaded.HaltReason() != sys.HaltReason() || !reflect.DeepEqual(loaded.Fault(), sys.Fault()) {
+				t.Errorf("Loaded machine stopped with %s %v, expected %s %v", loaded.HaltReason(), loaded.Fault(), sys.HaltReason(), sys.Fault())
+			}
+
+			// both carry on the same way, down to the random numbers
+			sys.RunCycles(20)
+			loaded.RunCycles(20)
+			if !bytes.Equal(saveState(t, loaded), saveState(t, sys)) {
+				t.Errorf("Loaded machine runs differently from the saved one")
+			}
+		})
+	}
+}
+
+func TestLoadStateRejectsInvalidStates(t *testing.T) {
+	// offsets of fields in a state of a CHIP-8 machine
+	const (
+		variantOffset = 6
+		memorySizeOffset = variantOffset + 1 + 6
+		registersOffset = memorySizeOffset + 4 + MEMORY_SIZE
+		stackOffset = registersOffset + REGISTER_COUNT + 3 * 2
+		// after I, PC, the opcode, the stack and the timers
+		hiresOffset = stackOffset + 2 * 2 + STACK_SIZE * 2 + 2
+		planesOffset = hiresOffset + 1
+		heightOffset = planesOffset + 2
+		// after the display and the keys
+		pressedKeyOffset = heightOffset + 2 * 2 + DISPLAY_WIDTH * DISPLAY_HEIGHT + KEY_COUNT + 1
+	)
+
+	tests := []struct {
+		name string
+		change func(state []byte) []byte
+	}{
+		{"magic", func(state []byte) []byte { state[0] = 'X'; return state }},
+		{"version", func(state []byte) []byte { state[5] = STATE_VERSION + 1; return state }},
+		{"variant", func(state []byte) []byte { state[variantOffset] = 9; return state }},
+		{"memory size for the variant", func(state []byte) []byte {
+			binary.BigEndian.PutUint32(state[memorySizeOffset:], XOCHIP_MEMORY_SIZE)
+			return state
+		}},
+		{"stack capacity", func(state []byte) []byte {
+			binary.BigEndian.PutUint16(state[stackOffset:], STACK_SIZE + 1)
+			return state
+		}},
+		{"stack index", func(state []byte) []byte {
+			binary.BigEndian.PutUint16(state[stackOffset + 2:], STACK_SIZE + 1)
+			return state
+		}},
+		{"hi-res on CHIP-8", func(state []byte) []byte { state[hiresOffset] = 1; return state }},
+		{"planes on CHIP-8", func(state []byte) []byte { state[planesOffset] = 3; return state }},
+		{"display size", func(state []byte) []byte { state[heightOffset + 1] = 64; return state }},
+		{"pressed key", func(state []byte) []byte { state[pressedKeyOffset] = KEY_COUNT; return state }},
+		{"no pressed key", func(state []byte) []byte { state[pressedKeyOffset] = 0xFE; return state }},
+		{"random numbers", func(state []byte) []byte {
+			binary.BigEndian.PutUint64(state[len(state) - 8:], 1 << 40)
+			return state
+		}},
+		{"truncated", func(state []byte) []byte { return state[:len(state) - 1] }},
+	}
+
+	rom := []byte{0xC0, 0xFF, 0x12, 0x00}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sys := newTestSystem(t, rom, WithSeed(5))
+			sys.RunCycles(10)
+			state := test.change(saveState(t, sys))
+
+			loaded := newTestSystem(t, []byte{0x12, 0x00})
+			before := saveState(t, loaded)
+			err := loaded.LoadState(bytes.NewReader(state))
+			if err == nil {
+				t.Fatalf("Invalid state was loaded")
+			}
+			if !bytes.Equal(saveState(t, loaded), before) {
+				t.Errorf("Machine was changed by a state that failed to load")
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package chip8
+
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+
+// SourceLine is a line of the source a ROM was assembled from
+type SourceLine struct {
+	File string
+	Line int
+}
+
+
+// SourceMap relates the addresses of a ROM to the source lines and labels it
+// was assembled from. As text, it has one entry per line:
+//
+//	line ADDR LINE FILE
+//	symbol ADDR NAME
+//
+// with hexadecimal addresses and decimal line numbers. Empty lines and lines
+// starting with # are ignored.
+type SourceMap struct {
+	Lines map[uint16]SourceLine
+	Symbols map[uint16]string
+}
+
+
+func NewSourceMap() *SourceMap {
+	sourceMap := new(SourceMap)
+	sourceMap.Lines = make(map[uint16]SourceLine)
+	sourceMap.Symbols = make(map[uint16]string)
+
+	return sourceMap
+}
+
+
+// ReadSourceMap parses a source map in its text form
+func ReadSourceMap(in io.Reader) (*SourceMap, error) {
+	sourceMap := NewSourceMap()
+
+	scanner := bufio.NewScanner(in)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Source map line %d: expected 3 fields", number)
+		}
+
+		address, err := strconv.ParseUint(fields[1], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("Source map line %d: invalid address %q", number, fields[1])
+		}
+
+		switch fields[0] {
+		case "line":
+			parts := strings.SplitN(fields[2], " ", 2)
+			line, err := strconv.Atoi(parts[0])
+			if err != nil || len(parts) != 2 {
+				return nil, fmt.Errorf("Source map line %d: expected a line number and a file", number)
+			}
+
+			sourceMap.Lines[uint16(address)] = SourceLine{File: parts[1], Line: line}
+			break
+
+		case "symbol":
+			sourceMap.Symbols[uint16(address)] = fields[2]
+			break
+
+		default:
+			return nil, fmt.Errorf("Source map line %d: unknown entry %q", number, fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sourceMap, nil
+}
+
+// ReadSourceMapFile parses the source map stored at path
+func ReadSourceMapFile(path string) (*SourceMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSourceMap(file)
+}
+
+// Write stores the source map in its text form, ordered by address
+func (sourceMap *SourceMap) Write(out io.Writer) error {
+	writer := bufio.NewWriter(out)
+
+	for _, address := range sortedAddresses(sourceMap.Symbols) {
+		fmt.Fprintf(writer, "symbol %04X %s\n", address, sourceMap.Symbols[address])
+	}
+
+	addresses := make([]uint16, 0, len(sourceMap.Lines))
+	for address := range sourceMap.Lines {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i int, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+	for _, address := range addresses {
+		line := sourceMap.Lines[address]
+		fmt.Fprintf(writer, "line %04X %d %s\n", address, line.Line, line.File)
+	}
+
+	return writer.Flush()
+}
+
+// Line is the source line the instruction at address came from
+func (sourceMap *SourceMap) Line(address uint16) (SourceLine, bool) {
+	line, ok := sourceMap.Lines[address]
+	return line, ok
+}
+
+// Address is the lowest address assembled from a source line
+func (sourceMap *SourceMap) Address(line SourceLine) (uint16, bool) {
+	found := false
+	lowest := uint16(0)
+	for address, other := range sourceMap.Lines {
+		if other == line && (!found || address < lowest) {
+			lowest = address
+			found = true
+		}
+	}
+
+	return lowest, found
+}
+
+// Symbol names the closest label at or before address, such as the
+// subroutine it is part of
+func (sourceMap *SourceMap) Symbol(address uint16) (string, bool) {
+	addresses := sortedAddresses(sourceMap.Symbols)
+
+	index := sort.Search(len(addresses), func(i int) bool {
+		return addresses[i] > address
+	})
+	if index == 0 {
+		return "", false
+	}
+
+	return sourceMap.Symbols[addresses[index - 1]], true
+}
+
+func sortedAddresses(symbols map[uint16]string) []uint16 {
+	addresses := make([]uint16, 0, len(symbols))
+	for address := range symbols {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i int, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+
+	return addresses
+}
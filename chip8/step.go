@@ -0,0 +1,184 @@
+package chip8
+
+
+import (
+	"errors"
+)
+
+
+// ErrHalted is returned when stepping a machine whose program has exited or
+// which was quit
+var ErrHalted = errors.New("System is halted")
+
+
+// HaltReason is why a machine stopped running
+type HaltReason int
+
+const (
+	// still running
+	HALT_NONE HaltReason = iota
+	// the program exited with EXIT, 0x0000 or 0x0A00
+	HALT_EXIT
+	// the user quit
+	HALT_QUIT
+	// an instruction failed
+	HALT_FAULT
+)
+
+var HALT_REASON_NAMES = map[HaltReason]string{
+	HALT_NONE: "running",
+	HALT_EXIT: "exited",
+	HALT_QUIT: "quit",
+	HALT_FAULT: "fault",
+}
+
+
+func (reason HaltReason) String() string {
+	return HALT_REASON_NAMES[reason]
+}
+
+
+// StepResult describes a single executed instruction
+type StepResult struct {
+	Opcode uint16
+	PCBefore uint16
+	PCAfter uint16
+	// the program exited with this instruction
+	Halted bool
+}
+
+
+// Step executes exactly one instruction
+func (sys *System) Step() (StepResult, error) {
+	if sys.Halted() {
+		err := ErrHalted
+		if sys.haltReason == HALT_FAULT {
+			err = sys.fault
+		}
+		return StepResult{PCBefore: sys.programCounter, PCAfter: sys.programCounter, Halted: true}, err
+	}
+
+	result := StepResult{PCBefore: sys.programCounter}
+
+	sys.beginRecording()
+	defer sys.endRecording()
+
+	sys.pollInput()
+
+	tracing := sys.tracer != nil && sys.tracer.traces(sys.cycles, sys.programCounter)
+	var before []byte
+	if tracing {
+		before = append(before, sys.registers...)
+	}
+
+	err := sys.readInstruction()
+	result.Opcode = sys.opcode
+	if err != nil {
+		sys.stop(err)
+		result.PCAfter = sys.programCounter
+		result.Halted = true
+
+		// the instruction couldn't be read, so there is nothing to decode
+		if tracing {
+			sys.tracer.trace(sys, sys.cycles, result, Instruction{Opcode: sys.opcode}, before, err)
+		}
+		return result, err
+	}
+
+	var traced Instruction
+	if tracing {
+		traced = DecodeAt(sys.memory, int(sys.programCounter))
+	}
+
+	err = sys.parseInstruction()
+	if err != nil {
+		sys.stop(err)
+	}
+	sys.cycles++
+
+	if sys.cycles % sys.CyclesPerFrame() == 0 {
+		sys.frameDrawn = false
+
+		if sys.virtualClock {
+			sys.tickTimers()
+		}
+	}
+	// the timers tick as part of an instruction, so they are recorded along
+	// with it and never change while the machine is saved or inspected
+	if !sys.virtualClock {
+		sys.tickRealTime()
+	}
+
+	result.PCAfter = sys.programCounter
+	result.Halted = sys.Halted()
+
+	if tracing {
+		sys.tracer.trace(sys, sys.cycles - 1, result, traced, before, err)
+	}
+
+	return result, err
+}
+
+// RunCycles executes up to n instructions as fast as possible, stopping early
+// if the program halts or an instruction fails
+func (sys *System) RunCycles(n uint64) error {
+	for i := uint64(0); i < n && !sys.Halted(); i++ {
+		_, err := sys.Step()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunFrames executes as many instructions as would run in n frames of 1/60th
+// of a second at the clock speed. Combined with WithVirtualClock, the timers
+// tick once per frame, which makes runs reproducible.
+func (sys *System) RunFrames(n uint64) error {
+	return sys.RunCycles(n * sys.CyclesPerFrame())
+}
+
+// CyclesPerFrame is the number of instructions executed per 60 Hz frame
+func (sys *System) CyclesPerFrame() uint64 {
+	cycles := sys.clockspeed / 60
+	if cycles == 0 {
+		cycles = 1
+	}
+
+	return cycles
+}
+
+// Cycles is the number of instructions executed so far
+func (sys *System) Cycles() uint64 {
+	return sys.cycles
+}
+
+// Halted reports whether the machine stopped, because the program exited,
+// the user quit or an instruction failed
+func (sys *System) Halted() bool {
+	return sys.haltReason != HALT_NONE
+}
+
+// HaltReason is why the machine stopped, HALT_NONE while it runs
+func (sys *System) HaltReason() HaltReason {
+	return sys.haltReason
+}
+
+// Fault is the error of the instruction that stopped the machine, if one did
+func (sys *System) Fault() error {
+	return sys.fault
+}
+
+// Quit stops the machine on behalf of the user, unless it already stopped
+func (sys *System) Quit() {
+	if sys.haltReason == HALT_NONE {
+		sys.haltReason = HALT_QUIT
+	}
+}
+
+// stop halts the machine because of a failed instruction
+func (sys *System) stop(err error) {
+	sys.haltReason = HALT_FAULT
+	sys.fault = err
+}
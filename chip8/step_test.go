@@ -0,0 +1,72 @@
+package chip8
+
+
+import (
+	"bytes"
+	"testing"
+)
+
+
+// newTestSystem creates a machine with the font and rom loaded
+func newTestSystem(t *testing.T, rom []byte, options ...Option) *System {
+	t.Helper()
+
+	sys := NewSystem(options...)
+	sys.LoadFont()
+	err := sys.LoadROM(rom)
+	if err != nil {
+		t.Fatalf("Error loading ROM: %v", err)
+	}
+
+	return sys
+}
+
+// saveState snapshots the whole machine, which makes machines comparable
+func saveState(t *testing.T, sys *System) []byte {
+	t.Helper()
+
+	var state bytes.Buffer
+	err := sys.SaveState(&state)
+	if err != nil {
+		t.Fatalf("Error saving state: %v", err)
+	}
+
+	return state.Bytes()
+}
+
+
+func TestRunFramesIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		rom []byte
+	}{
+		// draws random numbers into V0 to V3, waits for the delay timer and
+		// draws a random digit
+		{"random", []byte{
+			0xC0, 0xFF, 0xC1, 0xFF, 0xC2, 0x0F, 0xC3, 0x3F,
+			0x64, 0x03, 0xF4, 0x15, 0xF5, 0x07, 0x35, 0x00,
+			0x12, 0x0C, 0xF2, 0x29, 0xD3, 0x15, 0x12, 0x00,
+		}},
+		{"draw", []byte{
+			0x60, 0x00, 0x61, 0x00, 0xA2, 0x0E, 0xD0, 0x11,
+			0x70, 0x07, 0x71, 0x01, 0x12, 0x04, 0xAA,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			run := func(seed int64) []byte {
+				sys := newTestSystem(t, test.rom, WithSeed(seed), WithVirtualClock(true))
+				err := sys.RunFrames(30)
+				if err != nil {
+					t.Fatalf("Error running: %v", err)
+				}
+				return saveState(t, sys)
+			}
+
+			if !bytes.Equal(run(42), run(42)) {
+				t.Errorf("Two runs with the same seed differ")
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package chip8
+
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"time"
+)
+
+const (
+	MEMORY_SIZE = 4096
+	XOCHIP_MEMORY_SIZE = 0x10000
+	REGISTER_COUNT = 16
+	STACK_SIZE = 16
+	KEY_COUNT = 16
+	PC_START = 0x200
+	DISPLAY_WIDTH = 64
+	DISPLAY_HEIGHT = 32
+	HIRES_DISPLAY_WIDTH = 128
+	HIRES_DISPLAY_HEIGHT = 64
+	// SUPER-CHIP persistent user flags
+	RPL_COUNT = 16
+	FONT_START = 0x000
+	BIG_FONT_START = 0x050
+	// XO-CHIP bitplanes
+	PLANE_COUNT = 2
+	// time between decrements of the timers
+	TIMER_PERIOD = time.Second / 60
+)
+
+/* Memory map
+ * +---------------+= 0xFFF (4095) End of Chip-8 RAM
+ * |               |
+ * |               |
+ * |               |
+ * |               |
+ * |               |
+ * | 0x200 to 0xFFF|
+ * |     Chip-8    |
+ * | Program / Data|
+ * |     Space     |
+ * |               |
+ * |               |
+ * |               |
+ * +- - - - - - - -+= 0x600 (1536) Start of ETI 660 Chip-8 programs
+ * |               |
+ * |               |
+ * |               |
+ * +---------------+= 0x200 (512) Start of most Chip-8 programs
+ * | 0x000 to 0x1FF|
+ * | Reserved for  |
+ * |  interpreter  |
+ * +---------------+= 0x000 (0) Start of Chip-8 RAM
+*/
+
+
+// System is a CHIP-8 machine. Create one with NewSystem, load a font and a
+// ROM into it and then Run it.
+type System struct {
+	// 4096 bytes, 64 KiB for XO-CHIP
+	memory []byte
+
+	// 16 byte registers
+	registers []byte
+	iregister uint16
+
+	delayTimer byte
+	soundTimer byte
+
+	programCounter uint16
+
+	stack *Stack
+
+	opcode uint16
+
+	display [][]byte
+	screen Display
+	audio Audio
+	// SUPER-CHIP 128x64 mode
+	hires bool
+	// XO-CHIP bitmask of the planes drawn to
+	planes byte
+
+	variant Variant
+	quirks Quirks
+	quirksSet bool
+	rplFlags []byte
+	// a sprite was drawn this frame, for the display wait quirk
+	frameDrawn bool
+
+	keys []bool
+	input Input
+	// set while FX0A waits for a key, holds the key once pressed or -1
+	waitingForKey bool
+	pressedKey int
+
+	// why the machine stopped, with the error for faults
+	haltReason HaltReason
+	fault error
+
+	// number of instructions executed
+	cycles uint64
+
+	// length of the loaded ROM
+	romSize int
+
+	// wrap addresses and keys around instead of faulting
+	wrapMemory bool
+
+	// undo history for StepBack, and the entry of the running instruction
+	rewind *rewindBuffer
+	recording *rewindEntry
+
+	// writes a line per executed instruction when set
+	tracer *Tracer
+
+	// called after every store to memory, used by watchpoints
+	onWrite func(address uint16, old byte, value byte)
+
+	// source for RND, seeded so runs can be reproduced
+	seed int64
+	randomSource *countingSource
+	random *rand.Rand
+
+	// Hz
+	clockspeed uint64
+	// timers are decremented every CyclesPerFrame instructions instead of in
+	// real time
+	virtualClock bool
+	// when the timers are decremented next in real time, zero until the first
+	// instruction
+	timerDue time.Time
+
+	debug bool
+}
+
+
+// NewSystem creates a machine with the program counter at PC_START. Without
+// options it runs at 500 Hz without any display or input attached, with a
+// random seed taken from the current time.
+func NewSystem(options ...Option) *System {
+	sys := new(System)
+	sys.registers = make([]byte, REGISTER_COUNT)
+	sys.stack = newStack(STACK_SIZE)
+	sys.programCounter = PC_START
+	sys.clockspeed = 500
+	sys.screen = nullDisplay{}
+	sys.input = nullInput{}
+	sys.audio = nullAudio{}
+	sys.seed = time.Now().UnixNano()
+
+	sys.keys = make([]bool, KEY_COUNT)
+	sys.pressedKey = -1
+
+	sys.rplFlags = make([]byte, RPL_COUNT)
+	sys.planes = 0x1
+
+	for _, option := range options {
+		option(sys)
+	}
+
+	sys.memory = make([]byte, sys.variant.MemorySize())
+
+	if !sys.quirksSet {
+		sys.quirks = DefaultQuirks(sys.variant)
+	}
+
+	sys.setRandom(sys.seed, 0)
+	sys.setResolution(false)
+
+	return sys
+}
+
+func (sys *System) incrementPC(skip bool) {
+	if !skip {
+		sys.programCounter += 2
+	} else if sys.variant >= VARIANT_XOCHIP {
+		// XO-CHIP skips over the whole 4 byte long load
+		next := DecodeAt(sys.memory, int(sys.programCounter) + 2)
+		sys.programCounter += 2 + next.Size()
+	} else {
+		sys.programCounter += 4
+	}
+}
+
+// Run executes instructions at the clock speed until the program halts, stop
+// is signalled or an instruction fails
+func (sys *System) Run(stop <-chan bool) error {
+	ticker := time.NewTicker(time.Second / time.Duration(sys.clockspeed))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		select {
+		case <-stop:
+			sys.Quit()
+			return nil
+		default:
+			_, err := sys.Step()
+			if err != nil {
+				return err
+			}
+
+			if sys.Halted() {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// decrement the timers once for every TIMER_PERIOD passed since they were
+// last decremented. After a pause of more than a second, such as the machine
+// not being stepped, they carry on from now instead of catching up.
+func (sys *System) tickRealTime() {
+	now := time.Now()
+	if sys.timerDue.IsZero() || now.Sub(sys.timerDue) > time.Second {
+		sys.timerDue = now.Add(TIMER_PERIOD)
+		return
+	}
+
+	for !now.Before(sys.timerDue) {
+		sys.tickTimers()
+		sys.timerDue = sys.timerDue.Add(TIMER_PERIOD)
+	}
+}
+
+// decrement the timers, 60 times a second
+func (sys *System) tickTimers() {
+	sys.audio.Tick(sys.soundTimer > 0)
+
+	if sys.soundTimer > 0 {
+		sys.soundTimer--
+	}
+
+	if sys.delayTimer > 0 {
+		sys.delayTimer--
+	}
+}
+
+// apply the pending key events to the key state
+func (sys *System) pollInput() {
+	for _, event := range sys.input.Events() {
+		if int(event.Key) >= len(sys.keys) {
+			continue
+		}
+
+		sys.keys[event.Key] = event.Pressed
+		if event.Pressed && sys.waitingForKey && sys.pressedKey < 0 {
+			sys.pressedKey = int(event.Key)
+		}
+	}
+}
+
+// LoadFont puts the hex digit sprites at the start of memory
+func (sys *System) LoadFont() error {
+	fonts := []byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0,
+		0x20, 0x60, 0x20, 0x20, 0x70,
+		0xF0, 0x10, 0xF0, 0x80, 0xF0,
+		0xF0, 0x10, 0xF0, 0x10, 0xF0,
+		0x90, 0x90, 0xF0, 0x10, 0x10,
+		0xF0, 0x80, 0xF0, 0x10, 0xF0,
+		0xF0, 0x80, 0xF0, 0x90, 0xF0,
+		0xF0, 0x10, 0x20, 0x40, 0x40,
+		0xF0, 0x90, 0xF0, 0x90, 0xF0,
+		0xF0, 0x90, 0xF0, 0x10, 0xF0,
+		0xF0, 0x90, 0xF0, 0x90, 0x90,
+		0xE0, 0x90, 0xE0, 0x90, 0xE0,
+		0xF0, 0x80, 0x80, 0x80, 0xF0,
+		0xE0, 0x90, 0x90, 0x90, 0xE0,
+		0xF0, 0x80, 0xF0, 0x80, 0xF0,
+		0xF0, 0x80, 0xF0, 0x80, 0x80,
+	}
+
+	// 8x10 digits for the SUPER-CHIP hi-res mode
+	bigFonts := []byte{
+		0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C,
+		0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C,
+		0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF,
+		0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C,
+		0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06,
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C,
+		0x3E, 0x7C, 0xE0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C,
+		0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60,
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C,
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C,
+		0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3,
+		0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC,
+		0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C,
+		0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC,
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF,
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0,
+	}
+
+	for i, x := range fonts {
+		sys.memory[FONT_START + i] = x
+	}
+
+	for i, x := range bigFonts {
+		sys.memory[BIG_FONT_START + i] = x
+	}
+
+	return nil
+}
+
+// LoadROMFile loads the ROM at path into memory at PC_START
+func (sys *System) LoadROMFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return sys.LoadROM(data)
+}
+
+// LoadROM copies the ROM into memory at PC_START, as long as it fits
+func (sys *System) LoadROM(data []byte) error {
+	if len(data) > len(sys.memory) - PC_START {
+		return &ROMTooLarge{Size: len(data), Max: len(sys.memory) - PC_START}
+	}
+
+	for i, b := range data {
+		sys.memory[PC_START + i] = b
+	}
+	sys.romSize = len(data)
+
+	return nil
+}
+
+// ROM returns the loaded ROM as it is in memory now
+func (sys *System) ROM() []byte {
+	return sys.ReadMemory(PC_START, sys.romSize)
+}
+
+func (sys *System) readInstruction() error {
+	sys.opcode = 0
+
+	high, err := sys.readMemory(int(sys.programCounter))
+	if err != nil {
+		return err
+	}
+	low, err := sys.readMemory(int(sys.programCounter) + 1)
+	if err != nil {
+		return err
+	}
+
+	sys.opcode = (uint16(high) << 8) | uint16(low)
+	return nil
+}
+
+// writeMemory is the path every store to memory by an instruction takes, so it
+// can be undone and watched
+func (sys *System) writeMemory(address int, value byte) error {
+	address, err := sys.address(address, true)
+	if err != nil {
+		return err
+	}
+
+	old := sys.memory[address]
+	if sys.recording != nil {
+		sys.recording.memory = append(sys.recording.memory, memoryChange{uint16(address), old})
+	}
+
+	sys.memory[address] = value
+
+	if sys.onWrite != nil {
+		sys.onWrite(uint16(address), old, value)
+	}
+
+	return nil
+}
+
+// seed the random number source and skip the first draws numbers
+func (sys *System) setRandom(seed int64, draws uint64) {
+	sys.seed = seed
+	sys.randomSource = newCountingSource(seed, draws)
+	sys.random = rand.New(sys.randomSource)
+}
+
+// Clockspeed is the number of instructions executed per second by Run
+func (sys *System) Clockspeed() uint64 {
+	return sys.clockspeed
+}
+
+// Seed is the seed of the random number source used by RND
+func (sys *System) Seed() int64 {
+	return sys.seed
+}
+
+// Framebuffer returns a copy of the display, indexed as frame[y][x] with one
+// bit per plane in every pixel
+func (sys *System) Framebuffer() [][]byte {
+	frame := make([][]byte, len(sys.display))
+	for i := 0; i < len(sys.display); i++ {
+		frame[i] = make([]byte, len(sys.display[i]))
+		copy(frame[i], sys.display[i])
+	}
+
+	return frame
+}
+
+// Variant is the platform the system emulates
+func (sys *System) Variant() Variant {
+	return sys.variant
+}
+
+// Quirks are the platform behaviours the system follows
+func (sys *System) Quirks() Quirks {
+	return sys.quirks
+}
+
+// switch between the 64x32 and the SUPER-CHIP 128x64 framebuffer, which also
+// clears it
+func (sys *System) setResolution(hires bool) error {
+	width := DISPLAY_WIDTH
+	height := DISPLAY_HEIGHT
+	if hires {
+		width = HIRES_DISPLAY_WIDTH
+		height = HIRES_DISPLAY_HEIGHT
+	}
+
+	sys.recordDisplay()
+	sys.hires = hires
+	sys.display = make([][]byte, height)
+	for i := 0; i < len(sys.display); i++ {
+		sys.display[i] = make([]byte, width)
+	}
+
+	return sys.screen.Resize(width, height)
+}
+
+// move the selected planes of the framebuffer by the given amount of pixels,
+// pixels moved in from outside are blank
+func (sys *System) scrollDisplay(dx int, dy int) {
+	sys.recordDisplay()
+
+	height := len(sys.display)
+	width := len(sys.display[0])
+
+	scrolled := make([][]byte, height)
+	for y := 0; y < height; y++ {
+		scrolled[y] = make([]byte, width)
+		for x := 0; x < width; x++ {
+			scrolled[y][x] = sys.display[y][x] &^ sys.planes
+
+			fromX := x - dx
+			fromY := y - dy
+			if fromX >= 0 && fromX < width && fromY >= 0 && fromY < height {
+				scrolled[y][x] |= sys.display[fromY][fromX] & sys.planes
+			}
+		}
+	}
+
+	sys.display = scrolled
+}
+
+// clear the selected planes
+func (sys *System) clearDisplay() {
+	sys.recordDisplay()
+
+	for i := 0; i < len(sys.display); i++ {
+		for j := 0; j < len(sys.display[i]); j++ {
+			sys.display[i][j] &^= sys.planes
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package chip8
+
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+
+type TraceFormat int
+
+const (
+	// one line of text per instruction
+	TRACE_TEXT TraceFormat = iota
+	// one JSON object per line
+	TRACE_JSON
+)
+
+var TRACE_FORMATS = map[string]TraceFormat{
+	"text": TRACE_TEXT,
+	"jsonl": TRACE_JSON,
+}
+
+
+// ParseTraceFormat looks up a trace format by its name, text or jsonl
+func ParseTraceFormat(name string) (TraceFormat, error) {
+	format, ok := TRACE_FORMATS[strings.ToLower(name)]
+	if !ok {
+		return TRACE_TEXT, fmt.Errorf("Unknown trace format %s", name)
+	}
+
+	return format, nil
+}
+
+
+// Tracer writes a line for every executed instruction, optionally limited to
+// a range of addresses and a range of cycles
+type Tracer struct {
+	out *bufio.Writer
+	format TraceFormat
+
+	// inclusive ranges of traced instructions
+	addressFrom uint16
+	addressTo uint16
+	cycleFrom uint64
+	cycleTo uint64
+
+	// the first error writing the trace
+	err error
+}
+
+// traceEntry is a line of a trace in JSON Lines form
+type traceEntry struct {
+	Cycle uint64 `json:"cycle"`
+	PC uint16 `json:"pc"`
+	Opcode uint16 `json:"opcode"`
+	Mnemonic string `json:"mnemonic"`
+	// old and new value of every changed V register
+	Changes map[string][2]byte `json:"changes"`
+	I uint16 `json:"i"`
+	SP uint `json:"sp"`
+	Error string `json:"error,omitempty"`
+}
+
+
+func NewTracer(out io.Writer, format TraceFormat) *Tracer {
+	tracer := new(Tracer)
+	tracer.out = bufio.NewWriter(out)
+	tracer.format = format
+	tracer.addressTo = 0xFFFF
+	tracer.cycleTo = ^uint64(0)
+
+	return tracer
+}
+
+
+// FilterAddresses only traces instructions from addresses from to to
+func (tracer *Tracer) FilterAddresses(from uint16, to uint16) {
+	tracer.addressFrom = from
+	tracer.addressTo = to
+}
+
+// FilterCycles only traces the instructions numbered from to to, counting the
+// executed instructions from 0
+func (tracer *Tracer) FilterCycles(from uint64, to uint64) {
+	tracer.cycleFrom = from
+	tracer.cycleTo = to
+}
+
+// Flush writes out buffered lines and reports the first error writing any
+func (tracer *Tracer) Flush() error {
+	err := tracer.out.Flush()
+	if tracer.err == nil {
+		tracer.err = err
+	}
+
+	return tracer.err
+}
+
+// traces reports whether the instruction at pc executed after cycle others
+// is traced
+func (tracer *Tracer) traces(cycle uint64, pc uint16) bool {
+	return cycle >= tracer.cycleFrom && cycle <= tracer.cycleTo && pc >= tracer.addressFrom && pc <= tracer.addressTo
+}
+
+// trace writes the line of an instruction, given the instruction and the
+// registers as they were before it ran
+func (tracer *Tracer) trace(sys *System, cycle uint64, result StepResult, inst Instruction, before []byte, stepErr error) {
+	if tracer.err != nil {
+		return
+	}
+
+	mnemonic := inst.String()
+
+	changes := []string{}
+	entry := traceEntry{
+		Cycle: cycle,
+		PC: result.PCBefore,
+		Opcode: result.Opcode,
+		Mnemonic: mnemonic,
+		Changes: make(map[string][2]byte),
+		I: sys.iregister,
+		SP: sys.stack.index,
+	}
+	for i, value := range sys.registers {
+		if value != before[i] {
+			name := fmt.Sprintf("V%X", i)
+			entry.Changes[name] = [2]byte{before[i], value}
+			changes = append(changes, fmt.Sprintf("%s=%02X->%02X", name, before[i], value))
+		}
+	}
+	if stepErr != nil {
+		entry.Error = stepErr.Error()
+	}
+
+	switch tracer.format {
+	case TRACE_JSON:
+		data, err := json.Marshal(entry)
+		if err != nil {
+			tracer.err = err
+			return
+		}
+		_, tracer.err = fmt.Fprintf(tracer.out, "%s\n", data)
+		break
+
+	default:
+		if stepErr != nil {
+			changes = append(changes, "error: " + stepErr.Error())
+		}
+		line := fmt.Sprintf(
+			"%d %04X %04X %-20s I=%04X SP=%X %s",
+			cycle, result.PCBefore, result.Opcode, mnemonic, sys.iregister, sys.stack.index, strings.Join(changes, " "),
+		)
+		_, tracer.err = fmt.Fprintln(tracer.out, strings.TrimRight(line, " "))
+		break
+	}
+}
@@ -0,0 +1,119 @@
+package chip8
+
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+
+// V0 = 0x12, V1 = 0x34, I = 0x300, CALL 0x20A, JMP 0x208, then at 0x20A
+// ADD V0, V1 and RET
+var TRACE_TEST_ROM = []byte{
+	0x60, 0x12, 0x61, 0x34, 0xA3, 0x00, 0x22, 0x0A,
+	0x12, 0x08, 0x80, 0x14, 0x00, 0xEE,
+}
+
+
+// traceROM traces the first cycles instructions of rom
+func traceROM(t *testing.T, rom []byte, cycles uint64, format TraceFormat, filter func(tracer *Tracer)) []string {
+	t.Helper()
+
+	var out bytes.Buffer
+	tracer := NewTracer(&out, format)
+	filter(tracer)
+
+	sys := newTestSystem(t, rom, WithTrace(tracer))
+	sys.RunCycles(cycles)
+
+	err := tracer.Flush()
+	if err != nil {
+		t.Fatalf("Error writing trace: %v", err)
+	}
+
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+
+func TestTraceText(t *testing.T) {
+	lines := traceROM(t, TRACE_TEST_ROM, 7, TRACE_TEXT, func(tracer *Tracer) {})
+
+	expected := []string{
+		"0 0200 6012 LD V0, 0x12          I=0000 SP=0 V0=00->12",
+		"1 0202 6134 LD V1, 0x34          I=0000 SP=0 V1=00->34",
+		"2 0204 A300 LD I, 0x300          I=0300 SP=0",
+		"3 0206 220A CALL 0x20A           I=0300 SP=1",
+		"4 020A 8014 ADD V0, V1           I=0300 SP=1 V0=12->46",
+		"5 020C 00EE RET                  I=0300 SP=0",
+		"6 0208 1208 JMP 0x208            I=0300 SP=0",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Got\n%s\nexpected\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	lines := traceROM(t, TRACE_TEST_ROM, 5, TRACE_JSON, func(tracer *Tracer) {})
+	if len(lines) != 5 {
+		t.Fatalf("Got %d lines, expected 5", len(lines))
+	}
+
+	var entry traceEntry
+	err := json.Unmarshal([]byte(lines[4]), &entry)
+	if err != nil {
+		t.Fatalf("Invalid line %q: %v", lines[4], err)
+	}
+
+	expected := traceEntry{
+		Cycle: 4,
+		PC: 0x20A,
+		Opcode: 0x8014,
+		Mnemonic: "ADD V0, V1",
+		Changes: map[string][2]byte{"V0": {0x12, 0x46}},
+		I: 0x300,
+		SP: 1,
+	}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("Got %+v, expected %+v", entry, expected)
+	}
+}
+
+func TestTraceFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		filter func(tracer *Tracer)
+		// cycles of the traced lines
+		cycles []string
+	}{
+		{"addresses", func(tracer *Tracer) { tracer.FilterAddresses(0x204, 0x20A) }, []string{"2", "3", "4", "6", "7"}},
+		{"cycles", func(tracer *Tracer) { tracer.FilterCycles(1, 3) }, []string{"1", "2", "3"}},
+		{"both", func(tracer *Tracer) {
+			tracer.FilterAddresses(0x208, 0x208)
+			tracer.FilterCycles(0, 6)
+		}, []string{"6"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := traceROM(t, TRACE_TEST_ROM, 8, TRACE_TEXT, test.filter)
+
+			cycles := []string{}
+			for _, line := range lines {
+				cycles = append(cycles, strings.Fields(line)[0])
+			}
+			if !reflect.DeepEqual(cycles, test.cycles) {
+				t.Errorf("Traced cycles %v, expected %v", cycles, test.cycles)
+			}
+		})
+	}
+}
+
+func TestTraceFault(t *testing.T) {
+	lines := traceROM(t, []byte{0x00, 0xEE}, 1, TRACE_TEXT, func(tracer *Tracer) {})
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "0 0200 00EE RET") || !strings.Contains(lines[0], " error: ") {
+		t.Errorf("Got %q, expected the RET with its error", lines)
+	}
+}
@@ -0,0 +1,55 @@
+package chip8
+
+
+import (
+	"fmt"
+	"strings"
+)
+
+
+// Variant is the platform a ROM was written for, each variant is a superset
+// of the ones before it
+type Variant int
+
+const (
+	VARIANT_CHIP8 Variant = iota
+	VARIANT_SCHIP
+	VARIANT_XOCHIP
+)
+
+
+var VARIANT_NAMES = map[Variant]string{
+	VARIANT_CHIP8: "chip8",
+	VARIANT_SCHIP: "schip",
+	VARIANT_XOCHIP: "xochip",
+}
+
+
+func (variant Variant) String() string {
+	name, ok := VARIANT_NAMES[variant]
+	if !ok {
+		return fmt.Sprintf("Variant(%d)", int(variant))
+	}
+
+	return name
+}
+
+// MemorySize is the number of bytes of memory of the platform
+func (variant Variant) MemorySize() int {
+	if variant >= VARIANT_XOCHIP {
+		return XOCHIP_MEMORY_SIZE
+	}
+
+	return MEMORY_SIZE
+}
+
+// ParseVariant looks up a variant by the name it is printed as
+func ParseVariant(name string) (Variant, error) {
+	for variant, variantName := range VARIANT_NAMES {
+		if strings.EqualFold(name, variantName) {
+			return variant, nil
+		}
+	}
+
+	return VARIANT_CHIP8, fmt.Errorf("Unknown variant %q", name)
+}
@@ -0,0 +1,369 @@
+package chip8
+
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+
+type WatchKind int
+
+const (
+	// pauses when an instruction stores to the address
+	WATCH_MEMORY WatchKind = iota
+	// pauses when the value of a register changes
+	WATCH_REGISTER
+	// pauses when a comparison becomes true
+	WATCH_CONDITION
+)
+
+
+// what a watch expression can refer to
+type operandKind int
+
+const (
+	OPERAND_CONSTANT operandKind = iota
+	OPERAND_V
+	OPERAND_I
+	OPERAND_PC
+	OPERAND_SP
+	OPERAND_DT
+	OPERAND_ST
+	OPERAND_MEMORY
+)
+
+// operand is a register, a byte of memory or a constant in a watch expression
+type operand struct {
+	kind operandKind
+	// register index, memory address or constant
+	value uint16
+}
+
+
+// comparison operators, two character ones first so they are matched first
+var COMPARISONS = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+
+// Watchpoint pauses the debugger when memory is written, a register changes
+// or a condition becomes true
+type Watchpoint struct {
+	ID int
+	Kind WatchKind
+	// the expression the watchpoint was created from
+	Expression string
+
+	// memory address for WATCH_MEMORY
+	Address uint16
+
+	left operand
+	comparison string
+	right operand
+	// value of the register or condition after the last instruction
+	last uint16
+}
+
+// WatchHit describes what triggered a watchpoint
+type WatchHit struct {
+	Watchpoint Watchpoint
+	// the instruction which triggered it
+	PC uint16
+	Opcode uint16
+	// value before and after the instruction, 0 and 1 for conditions
+	Old uint16
+	New uint16
+}
+
+
+func (hit WatchHit) String() string {
+	inst := Decode(hit.Opcode)
+
+	switch hit.Watchpoint.Kind {
+	case WATCH_MEMORY:
+		return fmt.Sprintf(
+			"Watchpoint %d: 0x%03X written, 0x%02X -> 0x%02X by %s at 0x%03X",
+			hit.Watchpoint.ID, hit.Watchpoint.Address, hit.Old, hit.New, inst, hit.PC,
+		)
+
+	case WATCH_REGISTER:
+		return fmt.Sprintf(
+			"Watchpoint %d: %s changed, 0x%02X -> 0x%02X by %s at 0x%03X",
+			hit.Watchpoint.ID, hit.Watchpoint.Expression, hit.Old, hit.New, inst, hit.PC,
+		)
+	}
+
+	return fmt.Sprintf(
+		"Watchpoint %d: %s became true by %s at 0x%03X",
+		hit.Watchpoint.ID, hit.Watchpoint.Expression, inst, hit.PC,
+	)
+}
+
+
+// Watch adds a watchpoint and returns its ID. The expression is one of
+//
+//	ADDR or [ADDR]      a store to the byte at ADDR
+//	V0 to VF, I, DT...  a change of the register
+//	A == B              the comparison becoming true
+//
+// Comparisons take registers (V0 to VF, I, PC, SP, DT and ST), bytes of
+// memory as [ADDR] and constants, and any of ==, !=, <, <=, > and >=.
+// Addresses and constants are hexadecimal, with or without a 0x prefix.
+func (dbg *Debugger) Watch(expression string) (int, error) {
+	expression = strings.TrimSpace(expression)
+	watch := Watchpoint{Expression: expression}
+
+	for _, comparison := range COMPARISONS {
+		index := strings.Index(expression, comparison)
+		if index < 0 {
+			continue
+		}
+
+		left, err := parseOperand(expression[:index])
+		if err != nil {
+			return 0, err
+		}
+		right, err := parseOperand(expression[index + len(comparison):])
+		if err != nil {
+			return 0, err
+		}
+
+		watch.Kind = WATCH_CONDITION
+		watch.left = left
+		watch.comparison = comparison
+		watch.right = right
+		break
+	}
+
+	if watch.comparison == "" {
+		target, err := parseOperand(expression)
+		if err != nil {
+			return 0, err
+		}
+
+		switch target.kind {
+		case OPERAND_CONSTANT, OPERAND_MEMORY:
+			watch.Kind = WATCH_MEMORY
+			watch.Address = target.value
+			break
+
+		default:
+			watch.Kind = WATCH_REGISTER
+			watch.left = target
+			break
+		}
+	}
+
+	if watch.Kind == WATCH_MEMORY && int(watch.Address) >= len(dbg.sys.memory) {
+		return 0, fmt.Errorf("Address 0x%X is outside of memory", watch.Address)
+	}
+
+	dbg.nextWatch++
+	watch.ID = dbg.nextWatch
+	watch.last = dbg.evaluate(watch)
+	dbg.watchpoints = append(dbg.watchpoints, watch)
+
+	return watch.ID, nil
+}
+
+// Unwatch removes the watchpoint with the given ID
+func (dbg *Debugger) Unwatch(id int) error {
+	for i, watch := range dbg.watchpoints {
+		if watch.ID == id {
+			dbg.watchpoints = append(dbg.watchpoints[:i], dbg.watchpoints[i + 1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("No watchpoint %d", id)
+}
+
+// Watchpoints lists the watchpoints in the order they were added
+func (dbg *Debugger) Watchpoints() []Watchpoint {
+	watchpoints := make([]Watchpoint, len(dbg.watchpoints))
+	copy(watchpoints, dbg.watchpoints)
+
+	return watchpoints
+}
+
+// refreshWatchpoints takes the current values as the ones to compare with, as
+// the system may have been changed outside of the debugger
+func (dbg *Debugger) refreshWatchpoints() {
+	for i := range dbg.watchpoints {
+		dbg.watchpoints[i].last = dbg.evaluate(dbg.watchpoints[i])
+	}
+}
+
+// LastHit is the watchpoint hit which last paused the debugger, if any
+func (dbg *Debugger) LastHit() (WatchHit, bool) {
+	if dbg.hit == nil {
+		return WatchHit{}, false
+	}
+
+	return *dbg.hit, true
+}
+
+// recordWrite is installed as the system's onWrite hook
+func (dbg *Debugger) recordWrite(address uint16, old byte, value byte) {
+	if dbg.hit != nil {
+		return
+	}
+
+	for _, watch := range dbg.watchpoints {
+		if watch.Kind == WATCH_MEMORY && watch.Address == address {
+			dbg.hit = &WatchHit{
+				Watchpoint: watch,
+				Opcode: dbg.sys.opcode,
+				Old: uint16(old),
+				New: uint16(value),
+			}
+			return
+		}
+	}
+}
+
+// checkWatchpoints looks for register and condition watchpoints triggered by
+// the instruction described by result, and returns the first hit including
+// memory writes recorded while it ran
+func (dbg *Debugger) checkWatchpoints(result StepResult) *WatchHit {
+	hit := dbg.hit
+
+	for i := range dbg.watchpoints {
+		watch := &dbg.watchpoints[i]
+		if watch.Kind == WATCH_MEMORY {
+			continue
+		}
+
+		value := dbg.evaluate(*watch)
+		old := watch.last
+		watch.last = value
+
+		if hit != nil || value == old {
+			continue
+		}
+		if watch.Kind == WATCH_CONDITION && value == 0 {
+			continue
+		}
+
+		hit = &WatchHit{
+			Watchpoint: *watch,
+			PC: result.PCBefore,
+			Opcode: result.Opcode,
+			Old: old,
+			New: value,
+		}
+	}
+
+	if hit != nil {
+		hit.PC = result.PCBefore
+	}
+
+	return hit
+}
+
+// evaluate gives the current value of a register watchpoint, or 1 if a
+// condition holds and 0 otherwise
+func (dbg *Debugger) evaluate(watch Watchpoint) uint16 {
+	switch watch.Kind {
+	case WATCH_REGISTER:
+		return dbg.value(watch.left)
+
+	case WATCH_CONDITION:
+		left := dbg.value(watch.left)
+		right := dbg.value(watch.right)
+
+		holds := false
+		switch watch.comparison {
+		case "==":
+			holds = left == right
+			break
+		case "!=":
+			holds = left != right
+			break
+		case "<":
+			holds = left < right
+			break
+		case "<=":
+			holds = left <= right
+			break
+		case ">":
+			holds = left > right
+			break
+		case ">=":
+			holds = left >= right
+			break
+		}
+
+		if holds {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (dbg *Debugger) value(target operand) uint16 {
+	sys := dbg.sys
+
+	switch target.kind {
+	case OPERAND_V:
+		return uint16(sys.registers[target.value])
+
+	case OPERAND_I:
+		return sys.iregister
+
+	case OPERAND_PC:
+		return sys.programCounter
+
+	case OPERAND_SP:
+		return uint16(sys.stack.index)
+
+	case OPERAND_DT:
+		return uint16(sys.delayTimer)
+
+	case OPERAND_ST:
+		return uint16(sys.soundTimer)
+
+	case OPERAND_MEMORY:
+		return uint16(sys.ReadMemory(int(target.value), 1)[0])
+	}
+
+	return target.value
+}
+
+func parseOperand(text string) (operand, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+
+	switch text {
+	case "I":
+		return operand{kind: OPERAND_I}, nil
+	case "PC":
+		return operand{kind: OPERAND_PC}, nil
+	case "SP":
+		return operand{kind: OPERAND_SP}, nil
+	case "DT":
+		return operand{kind: OPERAND_DT}, nil
+	case "ST":
+		return operand{kind: OPERAND_ST}, nil
+	}
+
+	if len(text) == 2 && text[0] == 'V' {
+		index, err := strconv.ParseUint(text[1:], 16, 8)
+		if err == nil {
+			return operand{kind: OPERAND_V, value: uint16(index)}, nil
+		}
+	}
+
+	kind := OPERAND_CONSTANT
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		kind = OPERAND_MEMORY
+		text = strings.TrimSpace(text[1:len(text) - 1])
+	}
+
+	value, err := strconv.ParseUint(strings.TrimPrefix(text, "0X"), 16, 16)
+	if err != nil {
+		return operand{}, fmt.Errorf("Invalid watch operand %q", text)
+	}
+
+	return operand{kind: kind, value: uint16(value)}, nil
+}
@@ -0,0 +1,101 @@
+package chip8
+
+
+import (
+	"testing"
+)
+
+
+func TestWatchpoints(t *testing.T) {
+	// V0 = 5, I = 0x300, store V0, V0 = 5 again, V1 = 7, then loop
+	rom := []byte{
+		0x60, 0x05, 0xA3, 0x00, 0xF0, 0x55, 0x60, 0x05,
+		0x61, 0x07, 0x12, 0x0A,
+	}
+
+	tests := []struct {
+		expression string
+		kind WatchKind
+		// address of the instruction that pauses, 0 if none does
+		pc uint16
+	}{
+		{"[300]", WATCH_MEMORY, 0x204},
+		{"0x301", WATCH_MEMORY, 0},
+		{"V0", WATCH_REGISTER, 0x200},
+		{"I", WATCH_REGISTER, 0x202},
+		{"V1 > V0", WATCH_CONDITION, 0x208},
+		{"[300] == 5", WATCH_CONDITION, 0x204},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			dbg := NewDebugger(newTestSystem(t, rom))
+			_, err := dbg.Watch(test.expression)
+			if err != nil {
+				t.Fatalf("Error adding watchpoint: %v", err)
+			}
+
+			dbg.Continue()
+			for i := 0; i < 10 && !dbg.Paused(); i++ {
+				_, err := dbg.Tick()
+				if err != nil {
+					t.Fatalf("Error stepping: %v", err)
+				}
+			}
+
+			hit, ok := dbg.LastHit()
+			if test.pc == 0 {
+				if ok {
+					t.Errorf("Paused at 0x%03X: %s", hit.PC, hit)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Did not pause, %s", dbg.Reason())
+			}
+			if hit.PC != test.pc || hit.Watchpoint.Kind != test.kind {
+				t.Errorf("Paused at 0x%03X for a watchpoint of kind %d, expected 0x%03X and %d", hit.PC, hit.Watchpoint.Kind, test.pc, test.kind)
+			}
+		})
+	}
+}
+
+func TestWatchpointIgnoredOnFault(t *testing.T) {
+	// I = 0xFFF, then storing V0 and V1 writes 0xFFF and fails at 0x1000
+	rom := []byte{0xAF, 0xFF, 0xF1, 0x55}
+	dbg := NewDebugger(newTestSystem(t, rom))
+	_, err := dbg.Watch("[FFF]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbg.StepInto()
+	_, err = dbg.StepInto()
+	if err == nil {
+		t.Fatalf("Storing past the end of memory did not fail")
+	}
+
+	if hit, ok := dbg.LastHit(); ok {
+		t.Errorf("Reported %s after the instruction failed", hit)
+	}
+}
+
+func TestDebuggersShareWrites(t *testing.T) {
+	rom := []byte{0xA3, 0x00, 0xF0, 0x55}
+	sys := newTestSystem(t, rom)
+
+	first := NewDebugger(sys)
+	first.Watch("[300]")
+	second := NewDebugger(sys)
+	second.Watch("[300]")
+
+	second.StepInto()
+	second.StepInto()
+
+	if _, ok := first.LastHit(); !ok {
+		t.Errorf("The first debugger did not see the write")
+	}
+	if _, ok := second.LastHit(); !ok {
+		t.Errorf("The second debugger did not see the write")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// runAnalyze implements the analyze subcommand and returns the exit status
+func runAnalyze(args []string) int {
+	var variantName string
+
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s analyze [flags] ROM\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&variantName, "variant", "", "Analyze the ROM as chip8, schip or xochip instead of guessing the variant")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+
+	rom, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error loading ROM: %v\n", err)
+		return 1
+	}
+
+	var analysis *chip8.Analysis
+	if variantName == "" {
+		analysis = chip8.AnalyzeROM(rom)
+	} else {
+		variant, err := chip8.ParseVariant(variantName)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		analysis = chip8.AnalyzeROMAs(rom, variant)
+	}
+	for _, finding := range analysis.Findings {
+		fmt.Printf("0x%04X: 0x%04X %s\n", finding.Address, finding.Opcode, finding.Message)
+	}
+	if len(analysis.Findings) == 0 {
+		fmt.Println("No problems found")
+	}
+
+	reasons := ""
+	if len(analysis.Reasons) > 0 {
+		reasons = " (" + strings.Join(analysis.Reasons, ", ") + ")"
+	}
+	fmt.Printf("Platform: --variant %s --quirks %s%s\n", analysis.Variant, analysis.Quirks, reasons)
+
+	return 0
+}
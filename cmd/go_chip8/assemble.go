@@ -0,0 +1,102 @@
+package main
+
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// runAssemble implements the assemble subcommand and returns the exit status
+func runAssemble(args []string) int {
+	var output string
+	var mapPath string
+	var variantName string
+
+	flags := flag.NewFlagSet("assemble", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s assemble [flags] SOURCE\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&output, "o", "", "ROM to write, defaults to the source with a .ch8 extension")
+	flags.StringVar(&mapPath, "map", "", "Also write a source map for --dap to this file")
+	flags.StringVar(&variantName, "variant", "chip8", "Platform whose instructions are allowed, chip8, schip or xochip")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 1
+	}
+	source := flags.Arg(0)
+
+	variant, err := chip8.ParseVariant(variantName)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(source, filepath.Ext(source)) + ".ch8"
+	}
+
+	rom, sourceMap, err := chip8.Assemble(source, variant)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	err = ioutil.WriteFile(output, rom, 0644)
+	if err != nil {
+		fmt.Printf("Error writing ROM: %v\n", err)
+		return 1
+	}
+
+	if mapPath != "" {
+		err = writeSourceMap(sourceMap, mapPath)
+		if err != nil {
+			fmt.Printf("Error writing source map: %v\n", err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// writeSourceMap stores the source map with file names relative to it, as
+// the debug adapter resolves them
+func writeSourceMap(sourceMap *chip8.SourceMap, path string) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	for address, line := range sourceMap.Lines {
+		file, err := filepath.Abs(line.File)
+		if err == nil {
+			file, err = filepath.Rel(dir, file)
+		}
+		if err == nil {
+			line.File = filepath.ToSlash(file)
+			sourceMap.Lines[address] = line
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = sourceMap.Write(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,148 @@
+package main
+
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jwoos/go_chip8/chip8"
+	"github.com/nsf/termbox-go"
+)
+
+
+// multiAudio plays the tone on several frontends at once
+type multiAudio []chip8.Audio
+
+
+func (audios multiAudio) Tick(sounding bool) {
+	for _, audio := range audios {
+		audio.Tick(sounding)
+	}
+}
+
+
+// termboxBell rings the terminal bell whenever the tone starts, for when no
+// other audio is set up
+type termboxBell struct {
+	sounding bool
+}
+
+
+func (bell *termboxBell) Tick(sounding bool) {
+	// termbox buffers what it draws, which is flushed first so the bell
+	// can't end up in the middle of an escape sequence
+	if sounding && !bell.sounding && termbox.Flush() == nil {
+		fmt.Fprint(os.Stdout, "\a")
+	}
+	bell.sounding = sounding
+}
+
+
+// playerAudio streams the tone as raw PCM to the standard input of a command
+// that plays it live
+type playerAudio struct {
+	*chip8.PCMAudio
+
+	cmd *exec.Cmd
+	stdin io.WriteCloser
+}
+
+
+func newPlayerAudio(command string) (*playerAudio, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("No player command given")
+	}
+
+	player := new(playerAudio)
+	player.cmd = exec.Command(fields[0], fields[1:]...)
+	player.cmd.Stderr = os.Stderr
+
+	stdin, err := player.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	player.stdin = stdin
+	player.PCMAudio = chip8.NewPCMAudio(stdin)
+
+	err = player.cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return player, nil
+}
+
+
+// Close ends the stream and waits for the player to finish
+func (player *playerAudio) Close() error {
+	player.stdin.Close()
+	err := player.cmd.Wait()
+	if player.Err() != nil {
+		return player.Err()
+	}
+
+	return err
+}
+
+
+// openAudio sets up recording the tone into a WAV file and playing it with a
+// player command, whichever are given. It returns nil if neither is, and a
+// function finishing them.
+func openAudio(wavPath string, player string) (chip8.Audio, func() error, error) {
+	audios := multiAudio{}
+	closers := []func() error{}
+	closeAll := func() error {
+		var first error
+		for _, closer := range closers {
+			err := closer()
+			if first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	if wavPath != "" {
+		file, err := os.Create(wavPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		wav, err := chip8.NewWAVAudio(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+
+		audios = append(audios, wav)
+		closers = append(closers, func() error {
+			err := wav.Close()
+			closeErr := file.Close()
+			if err != nil {
+				return err
+			}
+			return closeErr
+		})
+	}
+
+	if player != "" {
+		live, err := newPlayerAudio(player)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		audios = append(audios, live)
+		closers = append(closers, live.Close)
+	}
+
+	if len(audios) == 0 {
+		return nil, closeAll, nil
+	}
+
+	return audios, closeAll, nil
+}
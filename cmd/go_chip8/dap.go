@@ -0,0 +1,829 @@
+package main
+
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// the only thread a CHIP-8 has
+const DAP_THREAD = 1
+
+// variable references of the scopes, stack entries follow
+const (
+	DAP_REGISTERS = 1 + iota
+	DAP_TIMERS
+	DAP_STACK
+)
+
+
+type dapMessage struct {
+	Seq int `json:"seq"`
+	Type string `json:"type"`
+
+	// requests
+	Command string `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+
+	// responses
+	RequestSeq int `json:"request_seq,omitempty"`
+	Success *bool `json:"success,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	// events
+	Event string `json:"event,omitempty"`
+
+	Body interface{} `json:"body,omitempty"`
+}
+
+type dapLaunchArguments struct {
+	Program string `json:"program"`
+	SourceMap string `json:"sourceMap"`
+	StopOnEntry bool `json:"stopOnEntry"`
+	Variant string `json:"variant"`
+	Quirks string `json:"quirks"`
+}
+
+type dapSource struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line int `json:"line,omitempty"`
+	Message string `json:"message,omitempty"`
+	InstructionReference string `json:"instructionReference,omitempty"`
+}
+
+type dapVariable struct {
+	Name string `json:"name"`
+	Value string `json:"value"`
+	VariablesReference int `json:"variablesReference"`
+	MemoryReference string `json:"memoryReference,omitempty"`
+}
+
+
+// dapServer speaks the Debug Adapter Protocol to an editor over a pair of
+// streams, usually stdin and stdout
+type dapServer struct {
+	options []chip8.Option
+
+	in *bufio.Reader
+	out io.Writer
+	// guards out and seq
+	writeLock sync.Mutex
+	seq int
+
+	// set by launch
+	dbg *chip8.Debugger
+	sourceMap *chip8.SourceMap
+	// directory relative source map paths are resolved against
+	sourceDir string
+	stopOnEntry bool
+	// runs instructions at the clock speed while continuing
+	ticker *time.Ticker
+
+	// breakpoint addresses by source path, and of instruction breakpoints
+	sourceBreakpoints map[string][]uint16
+	instructionBreakpoints []uint16
+
+	// received requests, closed when the input ends
+	requests chan dapMessage
+}
+
+
+func newDAPServer(options []chip8.Option, in io.Reader, out io.Writer) *dapServer {
+	server := new(dapServer)
+	server.options = options
+	server.in = bufio.NewReader(in)
+	server.out = out
+	server.sourceBreakpoints = make(map[string][]uint16)
+	server.requests = make(chan dapMessage, 16)
+
+	return server
+}
+
+
+// runDAP serves the Debug Adapter Protocol until the editor disconnects
+func runDAP(options []chip8.Option, in io.Reader, out io.Writer) error {
+	server := newDAPServer(options, in, out)
+	go server.read()
+
+	return server.serve()
+}
+
+// serve handles requests, and runs the system at its clock speed while the
+// editor has it continue
+func (server *dapServer) serve() error {
+	defer func() {
+		if server.ticker != nil {
+			server.ticker.Stop()
+		}
+	}()
+
+	for {
+		// nothing runs before a ROM is launched
+		var tick <-chan time.Time
+		if server.ticker != nil {
+			tick = server.ticker.C
+		}
+
+		select {
+		case request, ok := <-server.requests:
+			if !ok {
+				return nil
+			}
+
+			done, err := server.handle(request)
+			if done || err != nil {
+				return err
+			}
+
+		case <-tick:
+			if server.dbg.Paused() {
+				continue
+			}
+
+			_, err := server.dbg.Tick()
+			if server.dbg.Paused() {
+				server.stopped(err)
+			}
+		}
+	}
+}
+
+// read decodes the requests framed by Content-Length headers
+func (server *dapServer) read() {
+	defer close(server.requests)
+
+	headers := textproto.NewReader(server.in)
+	for {
+		header, err := headers.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return
+		}
+
+		data := make([]byte, length)
+		_, err = io.ReadFull(server.in, data)
+		if err != nil {
+			return
+		}
+
+		var request dapMessage
+		if json.Unmarshal(data, &request) != nil || request.Type != "request" {
+			continue
+		}
+
+		server.requests <- request
+	}
+}
+
+func (server *dapServer) send(message dapMessage) error {
+	server.writeLock.Lock()
+	defer server.writeLock.Unlock()
+
+	server.seq++
+	message.Seq = server.seq
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(server.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (server *dapServer) respond(request dapMessage, body interface{}) error {
+	success := true
+	return server.send(dapMessage{
+		Type: "response",
+		RequestSeq: request.Seq,
+		Command: request.Command,
+		Success: &success,
+		Body: body,
+	})
+}
+
+func (server *dapServer) fail(request dapMessage, err error) error {
+	success := false
+	return server.send(dapMessage{
+		Type: "response",
+		RequestSeq: request.Seq,
+		Command: request.Command,
+		Success: &success,
+		Message: err.Error(),
+	})
+}
+
+func (server *dapServer) event(event string, body interface{}) error {
+	return server.send(dapMessage{Type: "event", Event: event, Body: body})
+}
+
+// stopped tells the editor why the system paused, or that the program ended
+func (server *dapServer) stopped(err error) {
+	sys := server.dbg.System()
+
+	if sys.HaltReason() == chip8.HALT_EXIT {
+		server.event("exited", map[string]interface{}{"exitCode": 0})
+		server.event("terminated", nil)
+		return
+	}
+
+	reason := "step"
+	if err != nil {
+		reason = "exception"
+	} else if _, ok := server.dbg.LastHit(); ok {
+		reason = "data breakpoint"
+	} else if strings.HasPrefix(server.dbg.Reason(), "Breakpoint") {
+		reason = "breakpoint"
+	} else if server.dbg.Reason() == "Paused" {
+		reason = "pause"
+	}
+
+	server.event("stopped", map[string]interface{}{
+		"reason": reason,
+		"description": server.dbg.Reason(),
+		"text": server.dbg.Reason(),
+		"threadId": DAP_THREAD,
+		"allThreadsStopped": true,
+	})
+}
+
+// handle answers a request, returning whether the session is over
+func (server *dapServer) handle(request dapMessage) (bool, error) {
+	if server.dbg == nil {
+		switch request.Command {
+		case "initialize", "launch", "disconnect", "terminate", "setExceptionBreakpoints":
+			break
+
+		default:
+			return false, server.fail(request, fmt.Errorf("No ROM has been launched"))
+		}
+	}
+
+	switch request.Command {
+	case "initialize":
+		err := server.respond(request, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsDisassembleRequest": true,
+			"supportsReadMemoryRequest": true,
+			"supportsInstructionBreakpoints": true,
+			"supportsSteppingGranularity": false,
+			"supportsTerminateRequest": true,
+		})
+		return false, err
+
+	case "launch":
+		err := server.launch(request.Arguments)
+		if err != nil {
+			return false, server.fail(request, err)
+		}
+
+		err = server.respond(request, nil)
+		if err != nil {
+			return false, err
+		}
+
+		// breakpoints can only be placed once the ROM is loaded
+		return false, server.event("initialized", nil)
+
+	case "configurationDone":
+		err := server.respond(request, nil)
+		if err != nil {
+			return false, err
+		}
+
+		if server.stopOnEntry {
+			server.dbg.Pause("Paused on entry")
+			return false, server.event("stopped", map[string]interface{}{
+				"reason": "entry",
+				"threadId": DAP_THREAD,
+				"allThreadsStopped": true,
+			})
+		}
+
+		server.dbg.Continue()
+		return false, nil
+
+	case "setBreakpoints":
+		body, err := server.setBreakpoints(request.Arguments)
+		if err != nil {
+			return false, server.fail(request, err)
+		}
+
+		return false, server.respond(request, body)
+
+	case "setInstructionBreakpoints":
+		body, err := server.setInstructionBreakpoints(request.Arguments)
+		if err != nil {
+			return false, server.fail(request, err)
+		}
+
+		return false, server.respond(request, body)
+
+	case "setExceptionBreakpoints":
+		return false, server.respond(request, map[string]interface{}{"breakpoints": []dapBreakpoint{}})
+
+	case "threads":
+		return false, server.respond(request, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": DAP_THREAD, "name": "CHIP-8"}},
+		})
+
+	case "stackTrace":
+		return false, server.respond(request, server.stackTrace())
+
+	case "scopes":
+		return false, server.respond(request, map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Registers", "variablesReference": DAP_REGISTERS, "expensive": false},
+				{"name": "Timers", "variablesReference": DAP_TIMERS, "expensive": false},
+				{"name": "Stack", "variablesReference": DAP_STACK, "expensive": false},
+			},
+		})
+
+	case "variables":
+		var arguments struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		json.Unmarshal(request.Arguments, &arguments)
+
+		return false, server.respond(request, map[string]interface{}{
+			"variables": server.variables(arguments.VariablesReference),
+		})
+
+	case "disassemble":
+		body, err := server.disassemble(request.Arguments)
+		if err != nil {
+			return false, server.fail(request, err)
+		}
+
+		return false, server.respond(request, body)
+
+	case "readMemory":
+		body, err := server.readMemory(request.Arguments)
+		if err != nil {
+			return false, server.fail(request, err)
+		}
+
+		return false, server.respond(request, body)
+
+	case "continue":
+		server.dbg.Continue()
+		return false, server.respond(request, map[string]interface{}{"allThreadsContinued": true})
+
+	case "pause":
+		err := server.respond(request, nil)
+		if err == nil && !server.dbg.Paused() {
+			server.dbg.Pause("Paused")
+			server.stopped(nil)
+		}
+		return false, err
+
+	case "next", "stepIn", "stepOut":
+		err := server.respond(request, nil)
+		if err != nil {
+			return false, err
+		}
+
+		var stepErr error
+		switch request.Command {
+		case "next":
+			_, stepErr = server.dbg.StepOver()
+			break
+		case "stepIn":
+			_, stepErr = server.dbg.StepInto()
+			break
+		case "stepOut":
+			_, stepErr = server.dbg.StepOut()
+			break
+		}
+
+		// stepping over or out of a subroutine runs until it returns
+		if server.dbg.Paused() {
+			server.stopped(stepErr)
+		}
+		return false, nil
+
+	case "disconnect", "terminate":
+		err := server.respond(request, nil)
+		if request.Command == "terminate" {
+			server.event("terminated", nil)
+			return false, err
+		}
+		return true, err
+	}
+
+	return false, server.fail(request, fmt.Errorf("Unsupported request %s", request.Command))
+}
+
+func (server *dapServer) launch(raw json.RawMessage) error {
+	var arguments dapLaunchArguments
+	err := json.Unmarshal(raw, &arguments)
+	if err != nil {
+		return err
+	}
+
+	if arguments.Program == "" {
+		return fmt.Errorf("No program given to launch")
+	}
+
+	options := append([]chip8.Option{}, server.options...)
+	if arguments.Variant != "" {
+		variant, err := chip8.ParseVariant(arguments.Variant)
+		if err != nil {
+			return err
+		}
+		options = append(options, chip8.WithVariant(variant))
+	}
+	if arguments.Quirks != "" {
+		quirks, err := chip8.ParseQuirks(arguments.Quirks)
+		if err != nil {
+			return err
+		}
+		options = append(options, chip8.WithQuirks(quirks))
+	}
+
+	sys := chip8.NewSystem(options...)
+	sys.LoadFont()
+	err = sys.LoadROMFile(arguments.Program)
+	if err != nil {
+		return err
+	}
+
+	// a relaunch without a source map must not keep the previous one
+	var sourceMap *chip8.SourceMap
+	sourceDir := ""
+	if arguments.SourceMap != "" {
+		sourceMap, err = chip8.ReadSourceMapFile(arguments.SourceMap)
+		if err != nil {
+			return err
+		}
+		sourceDir = filepath.Dir(arguments.SourceMap)
+	}
+
+	server.sourceMap = sourceMap
+	server.sourceDir = sourceDir
+	server.dbg = chip8.NewDebugger(sys)
+	server.stopOnEntry = arguments.StopOnEntry
+
+	if server.ticker != nil {
+		server.ticker.Stop()
+	}
+	server.ticker = time.NewTicker(time.Second / time.Duration(sys.Clockspeed()))
+
+	return nil
+}
+
+// sourcePath resolves a file named in the source map
+func (server *dapServer) sourcePath(file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(server.sourceDir, file)
+	}
+
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+
+	return path
+}
+
+// sourceLine finds the source map line of a source path and line number
+func (server *dapServer) sourceLine(path string, line int) (chip8.SourceLine, bool) {
+	if server.sourceMap == nil {
+		return chip8.SourceLine{}, false
+	}
+
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		absolute = filepath.Clean(path)
+	}
+
+	for _, other := range server.sourceMap.Lines {
+		if other.Line == line && server.sourcePath(other.File) == absolute {
+			return other, true
+		}
+	}
+
+	return chip8.SourceLine{}, false
+}
+
+func (server *dapServer) source(address uint16) (dapSource, int, bool) {
+	if server.sourceMap == nil {
+		return dapSource{}, 0, false
+	}
+
+	line, ok := server.sourceMap.Line(address)
+	if !ok {
+		return dapSource{}, 0, false
+	}
+
+	path := server.sourcePath(line.File)
+	return dapSource{Name: filepath.Base(path), Path: path}, line.Line, true
+}
+
+func (server *dapServer) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var arguments struct {
+		Source dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	err := json.Unmarshal(raw, &arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range server.sourceBreakpoints[arguments.Source.Path] {
+		server.dbg.ClearBreakpoint(address)
+	}
+	server.restoreBreakpoints()
+
+	addresses := []uint16{}
+	breakpoints := []dapBreakpoint{}
+	for _, requested := range arguments.Breakpoints {
+		breakpoint := dapBreakpoint{Line: requested.Line}
+
+		line, ok := server.sourceLine(arguments.Source.Path, requested.Line)
+		if ok {
+			address, _ := server.sourceMap.Address(line)
+			server.dbg.SetBreakpoint(address)
+			addresses = append(addresses, address)
+
+			breakpoint.Verified = true
+			breakpoint.InstructionReference = fmt.Sprintf("0x%03X", address)
+		} else if server.sourceMap == nil {
+			breakpoint.Message = "No source map was given"
+		} else {
+			breakpoint.Message = "No instruction was assembled from this line"
+		}
+
+		breakpoints = append(breakpoints, breakpoint)
+	}
+	server.sourceBreakpoints[arguments.Source.Path] = addresses
+
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (server *dapServer) setInstructionBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var arguments struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset int `json:"offset"`
+		} `json:"breakpoints"`
+	}
+	err := json.Unmarshal(raw, &arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range server.instructionBreakpoints {
+		server.dbg.ClearBreakpoint(address)
+	}
+	server.instructionBreakpoints = nil
+	server.restoreBreakpoints()
+
+	breakpoints := []dapBreakpoint{}
+	for _, requested := range arguments.Breakpoints {
+		address, err := dapAddress(requested.InstructionReference)
+		if err != nil {
+			breakpoints = append(breakpoints, dapBreakpoint{Message: err.Error()})
+			continue
+		}
+
+		address += requested.Offset
+		server.dbg.SetBreakpoint(uint16(address))
+		server.instructionBreakpoints = append(server.instructionBreakpoints, uint16(address))
+
+		breakpoint := dapBreakpoint{Verified: true, InstructionReference: fmt.Sprintf("0x%03X", address)}
+		if _, line, ok := server.source(uint16(address)); ok {
+			breakpoint.Line = line
+		}
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// restoreBreakpoints sets all breakpoints again, as source and instruction
+// breakpoints can share addresses and clearing one kind clears both
+func (server *dapServer) restoreBreakpoints() {
+	for _, addresses := range server.sourceBreakpoints {
+		for _, address := range addresses {
+			server.dbg.SetBreakpoint(address)
+		}
+	}
+
+	for _, address := range server.instructionBreakpoints {
+		server.dbg.SetBreakpoint(address)
+	}
+}
+
+// stackTrace has a frame for the current instruction and one for each call
+// which has not returned yet
+func (server *dapServer) stackTrace() interface{} {
+	sys := server.dbg.System()
+
+	addresses := []uint16{sys.Registers().PC}
+	calls := sys.CallStack()
+	for i := len(calls) - 1; i >= 0; i-- {
+		addresses = append(addresses, calls[i])
+	}
+
+	frames := []map[string]interface{}{}
+	for i, address := range addresses {
+		name := fmt.Sprintf("0x%03X", address)
+		if server.sourceMap != nil {
+			if symbol, ok := server.sourceMap.Symbol(address); ok {
+				name = symbol
+			}
+		}
+
+		frame := map[string]interface{}{
+			"id": i,
+			"name": name,
+			"line": 0,
+			"column": 0,
+			"instructionPointerReference": fmt.Sprintf("0x%03X", address),
+		}
+		if source, line, ok := server.source(address); ok {
+			frame["source"] = source
+			frame["line"] = line
+			frame["column"] = 1
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func (server *dapServer) variables(reference int) []dapVariable {
+	sys := server.dbg.System()
+	registers := sys.Registers()
+
+	variables := []dapVariable{}
+	switch reference {
+	case DAP_REGISTERS:
+		for i, value := range registers.V {
+			variables = append(variables, dapVariable{Name: fmt.Sprintf("V%X", i), Value: fmt.Sprintf("0x%02X", value)})
+		}
+		variables = append(
+			variables,
+			dapVariable{Name: "I", Value: fmt.Sprintf("0x%03X", registers.I), MemoryReference: fmt.Sprintf("0x%03X", registers.I)},
+			dapVariable{Name: "PC", Value: fmt.Sprintf("0x%03X", registers.PC), MemoryReference: fmt.Sprintf("0x%03X", registers.PC)},
+			dapVariable{Name: "SP", Value: fmt.Sprintf("%d", registers.SP)},
+		)
+		break
+
+	case DAP_TIMERS:
+		variables = append(
+			variables,
+			dapVariable{Name: "DT", Value: fmt.Sprintf("0x%02X", registers.DelayTimer)},
+			dapVariable{Name: "ST", Value: fmt.Sprintf("0x%02X", registers.SoundTimer)},
+		)
+		break
+
+	case DAP_STACK:
+		for i, address := range sys.CallStack() {
+			variables = append(variables, dapVariable{Name: fmt.Sprintf("%d", i), Value: fmt.Sprintf("0x%03X", address)})
+		}
+		break
+	}
+
+	return variables
+}
+
+// disassemble describes instructions around a memory reference with
+// DescribeOp. Instructions before it are assumed to be 2 bytes long.
+func (server *dapServer) disassemble(raw json.RawMessage) (interface{}, error) {
+	var arguments struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset int `json:"offset"`
+		InstructionOffset int `json:"instructionOffset"`
+		InstructionCount int `json:"instructionCount"`
+	}
+	err := json.Unmarshal(raw, &arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := dapAddress(arguments.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	address += arguments.Offset
+
+	sys := server.dbg.System()
+	// there can't be more instructions than bytes of memory
+	if arguments.InstructionCount < 0 || arguments.InstructionCount > sys.MemorySize() {
+		return nil, fmt.Errorf("Invalid instruction count %d", arguments.InstructionCount)
+	}
+	if arguments.InstructionOffset > sys.MemorySize() || arguments.InstructionOffset < -sys.MemorySize() {
+		return nil, fmt.Errorf("Invalid instruction offset %d", arguments.InstructionOffset)
+	}
+
+	if arguments.InstructionOffset < 0 {
+		address += arguments.InstructionOffset * 2
+	} else {
+		for i := 0; i < arguments.InstructionOffset; i++ {
+			address += int(sys.InstructionAt(address).Size())
+		}
+	}
+
+	instructions := []map[string]interface{}{}
+	for i := 0; i < arguments.InstructionCount; i++ {
+		instruction := map[string]interface{}{"address": fmt.Sprintf("0x%03X", address)}
+
+		if address < 0 || address >= sys.MemorySize() {
+			instruction["instruction"] = "??"
+			instruction["presentationHint"] = "invalid"
+			instructions = append(instructions, instruction)
+			address += 2
+			continue
+		}
+
+		inst := sys.InstructionAt(address)
+		instruction["instructionBytes"] = fmt.Sprintf("%X", sys.ReadMemory(address, int(inst.Size())))
+		instruction["instruction"] = chip8.DescribeOp(inst, sys.Variant(), sys.Quirks())
+
+		if server.sourceMap != nil {
+			if symbol, ok := server.sourceMap.Symbols[uint16(address)]; ok {
+				instruction["symbol"] = symbol
+			}
+		}
+		if source, line, ok := server.source(uint16(address)); ok {
+			instruction["location"] = source
+			instruction["line"] = line
+		}
+
+		instructions = append(instructions, instruction)
+		address += int(inst.Size())
+	}
+
+	return map[string]interface{}{"instructions": instructions}, nil
+}
+
+func (server *dapServer) readMemory(raw json.RawMessage) (interface{}, error) {
+	var arguments struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset int `json:"offset"`
+		Count int `json:"count"`
+	}
+	err := json.Unmarshal(raw, &arguments)
+	if err != nil {
+		return nil, err
+	}
+	if arguments.Count < 0 {
+		return nil, fmt.Errorf("Invalid count %d", arguments.Count)
+	}
+
+	address, err := dapAddress(arguments.MemoryReference)
+	if err != nil {
+		return nil, err
+	}
+	address += arguments.Offset
+
+	sys := server.dbg.System()
+	count := arguments.Count
+	if address < 0 || address >= sys.MemorySize() {
+		count = 0
+	} else if address + count > sys.MemorySize() {
+		count = sys.MemorySize() - address
+	}
+
+	return map[string]interface{}{
+		"address": fmt.Sprintf("0x%03X", address),
+		"data": base64.StdEncoding.EncodeToString(sys.ReadMemory(address, count)),
+		"unreadableBytes": arguments.Count - count,
+	}, nil
+}
+
+// dapAddress parses a memory or instruction reference such as 0x200
+func dapAddress(reference string) (int, error) {
+	address, err := strconv.ParseInt(reference, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid reference %q", reference)
+	}
+
+	return int(address), nil
+}
@@ -0,0 +1,176 @@
+package main
+
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+const DAP_TEST_SOURCE = `main:
+	LD V0, 5
+loop:
+	JMP loop
+`
+
+
+// writeDAPProgram assembles DAP_TEST_SOURCE into a ROM and a source map in
+// dir and returns their paths
+func writeDAPProgram(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	source := filepath.Join(dir, "main.asm")
+	err := ioutil.WriteFile(source, []byte(DAP_TEST_SOURCE), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rom, sourceMap, err := chip8.Assemble(source, chip8.VARIANT_CHIP8)
+	if err != nil {
+		t.Fatalf("Error assembling: %v", err)
+	}
+
+	romPath := filepath.Join(dir, "main.ch8")
+	err = ioutil.WriteFile(romPath, rom, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mapData bytes.Buffer
+	err = sourceMap.Write(&mapData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapPath := filepath.Join(dir, "main.map")
+	err = ioutil.WriteFile(mapPath, mapData.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return romPath, mapPath
+}
+
+// dapRequest has the server handle a request and returns its response
+func dapRequest(t *testing.T, server *dapServer, out *bytes.Buffer, command string, arguments interface{}) dapMessage {
+	t.Helper()
+
+	raw, err := json.Marshal(arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	_, err = server.handle(dapMessage{Seq: 1, Type: "request", Command: command, Arguments: raw})
+	if err != nil {
+		t.Fatalf("Error handling %s: %v", command, err)
+	}
+
+	// the response comes before any events
+	reader := bufio.NewReader(out)
+	header, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("No response to %s: %v", command, err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	data := make([]byte, length)
+	reader.Read(data)
+
+	var response dapMessage
+	err = json.Unmarshal(data, &response)
+	if err != nil || response.Type != "response" || response.Command != command {
+		t.Fatalf("Invalid response to %s: %s", command, data)
+	}
+
+	return response
+}
+
+
+func TestDAPRelaunchDropsSourceMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	romPath, mapPath := writeDAPProgram(t, dir)
+
+	var out bytes.Buffer
+	server := newDAPServer(nil, nil, &out)
+	defer func() {
+		if server.ticker != nil {
+			server.ticker.Stop()
+		}
+	}()
+
+	dapRequest(t, server, &out, "launch", dapLaunchArguments{Program: romPath, SourceMap: mapPath})
+	if _, line, ok := server.source(0x202); !ok || line != 4 {
+		t.Fatalf("0x202 is on line %d, expected 4", line)
+	}
+
+	dapRequest(t, server, &out, "launch", dapLaunchArguments{Program: romPath})
+	if server.sourceMap != nil {
+		t.Errorf("Kept the source map of the previous launch")
+	}
+
+	response := dapRequest(t, server, &out, "setBreakpoints", map[string]interface{}{
+		"source": dapSource{Path: filepath.Join(dir, "main.asm")},
+		"breakpoints": []map[string]int{{"line": 4}},
+	})
+	data, _ := json.Marshal(response.Body)
+	if !bytes.Contains(data, []byte("No source map was given")) {
+		t.Errorf("Breakpoint was placed without a source map: %s", data)
+	}
+}
+
+func TestDAPRejectsInvalidArguments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	romPath, _ := writeDAPProgram(t, dir)
+
+	var out bytes.Buffer
+	server := newDAPServer(nil, nil, &out)
+	defer func() {
+		if server.ticker != nil {
+			server.ticker.Stop()
+		}
+	}()
+
+	response := dapRequest(t, server, &out, "readMemory", map[string]interface{}{"memoryReference": "0x200", "count": 2})
+	if *response.Success {
+		t.Errorf("Read memory before launching")
+	}
+
+	dapRequest(t, server, &out, "launch", dapLaunchArguments{Program: romPath})
+
+	tests := []struct {
+		command string
+		arguments map[string]interface{}
+		success bool
+	}{
+		{"readMemory", map[string]interface{}{"memoryReference": "0x200", "count": 2}, true},
+		{"readMemory", map[string]interface{}{"memoryReference": "0x200", "count": -1}, false},
+		{"readMemory", map[string]interface{}{"memoryReference": "main", "count": 2}, false},
+		{"disassemble", map[string]interface{}{"memoryReference": "0x200", "instructionCount": 2}, true},
+		{"disassemble", map[string]interface{}{"memoryReference": "0x200", "instructionCount": 1 << 30}, false},
+		{"disassemble", map[string]interface{}{"memoryReference": "0x200", "instructionOffset": -(1 << 30), "instructionCount": 1}, false},
+		{"evaluate", map[string]interface{}{}, false},
+	}
+
+	for _, test := range tests {
+		response := dapRequest(t, server, &out, test.command, test.arguments)
+		if *response.Success != test.success {
+			t.Errorf("%s %v succeeded: %v, expected %v (%s)", test.command, test.arguments, *response.Success, test.success, response.Message)
+		}
+	}
+}
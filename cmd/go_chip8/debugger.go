@@ -0,0 +1,402 @@
+package main
+
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+	"github.com/nsf/termbox-go"
+)
+
+
+// columns the panes need next to the game screen, below it otherwise
+const PANE_WIDTH = 80
+
+// number of instructions shown from PC onwards
+const CODE_LINES = 8
+
+// rows of 8 bytes shown around an address in the memory views
+const MEMORY_ROWS = 4
+
+const PROMPT = "(dbg) "
+
+
+// debugView draws the debugger panes next to the game screen and runs the
+// commands typed into its command line
+type debugView struct {
+	dbg *chip8.Debugger
+	display *termboxDisplay
+	rom string
+
+	command string
+	// repeated when an empty command is entered
+	lastCommand string
+	message string
+
+	// column and row the panes start at
+	x int
+	y int
+	// row drawn to next
+	row int
+}
+
+
+func newDebugView(dbg *chip8.Debugger, display *termboxDisplay, rom string) *debugView {
+	view := new(debugView)
+	view.dbg = dbg
+	view.display = display
+	view.rom = rom
+
+	return view
+}
+
+
+// runDebugger runs the system under the debugger, starting out paused. While
+// paused, typed keys go to the command line instead of the system.
+func runDebugger(sys *chip8.System, input *termboxInput, display *termboxDisplay, rom string) error {
+	dbg := chip8.NewDebugger(sys)
+	view := newDebugView(dbg, display, rom)
+
+	input.mute(true)
+	view.draw()
+
+	ticker := time.NewTicker(time.Second / time.Duration(sys.Clockspeed()))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-input.quit:
+			sys.Quit()
+			return nil
+
+		case ev := <-input.keys:
+			// errors of instructions are shown by the view and kept as the
+			// fault of the system
+			quit, _ := view.key(ev)
+			if quit {
+				sys.Quit()
+				return nil
+			}
+
+			input.mute(dbg.Paused())
+			view.draw()
+
+		case <-ticker.C:
+			if dbg.Paused() {
+				continue
+			}
+
+			dbg.Tick()
+
+			if dbg.Paused() {
+				input.mute(true)
+				view.message = ""
+				view.draw()
+			} else if sys.Cycles() % sys.CyclesPerFrame() == 0 {
+				view.draw()
+			}
+		}
+	}
+}
+
+// key handles a key press, returning whether the debugger should quit
+func (view *debugView) key(ev termbox.Event) (bool, error) {
+	switch ev.Key {
+	case termbox.KeyF9:
+		if view.dbg.Paused() {
+			view.dbg.Continue()
+		} else {
+			view.dbg.Pause("Paused")
+		}
+		return false, nil
+
+	case termbox.KeyF10:
+		_, err := view.dbg.StepOver()
+		return false, err
+
+	case termbox.KeyF11:
+		_, err := view.dbg.StepInto()
+		return false, err
+	}
+
+	// the game has the keyboard while running
+	if !view.dbg.Paused() {
+		return false, nil
+	}
+
+	switch {
+	case ev.Key == termbox.KeyEnter:
+		command := view.command
+		view.command = ""
+		if command == "" {
+			command = view.lastCommand
+		}
+		view.lastCommand = command
+		return view.run(command)
+
+	case (ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2) && view.command != "":
+		view.command = view.command[:len(view.command) - 1]
+		return false, nil
+
+	case ev.Key == termbox.KeySpace:
+		view.command += " "
+		return false, nil
+
+	case ev.Ch != 0:
+		view.command += string(ev.Ch)
+		return false, nil
+	}
+
+	if hotkey(view.dbg.System(), view.display, view.rom, ev) {
+		view.message = ""
+	}
+
+	return false, nil
+}
+
+// run executes a command typed into the command line
+func (view *debugView) run(command string) (bool, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	view.message = ""
+
+	switch fields[0] {
+	case "s", "step":
+		_, err := view.dbg.StepInto()
+		return false, err
+
+	case "n", "next":
+		_, err := view.dbg.StepOver()
+		return false, err
+
+	case "c", "continue":
+		view.dbg.Continue()
+		break
+
+	case "back":
+		err := view.dbg.System().StepBack()
+		if err != nil {
+			view.message = err.Error()
+		} else {
+			view.dbg.Pause("Stepped back")
+		}
+		break
+
+	case "b", "break", "d", "delete":
+		if len(fields) != 2 {
+			view.message = fmt.Sprintf("Usage: %s ADDRESS", fields[0])
+			break
+		}
+
+		address, err := parseAddress(fields[1])
+		if err != nil {
+			view.message = err.Error()
+			break
+		}
+
+		if fields[0] == "b" || fields[0] == "break" {
+			view.dbg.SetBreakpoint(address)
+			view.message = fmt.Sprintf("Breakpoint set at 0x%03X", address)
+		} else {
+			view.dbg.ClearBreakpoint(address)
+			view.message = fmt.Sprintf("Breakpoint at 0x%03X deleted", address)
+		}
+		break
+
+	case "w", "watch":
+		expression := strings.TrimSpace(strings.TrimPrefix(command, fields[0]))
+		id, err := view.dbg.Watch(expression)
+		if err != nil {
+			view.message = err.Error()
+		} else {
+			view.message = fmt.Sprintf("Watchpoint %d set on %s", id, expression)
+		}
+		break
+
+	case "unwatch":
+		if len(fields) != 2 {
+			view.message = "Usage: unwatch ID"
+			break
+		}
+
+		id, err := strconv.Atoi(fields[1])
+		if err == nil {
+			err = view.dbg.Unwatch(id)
+		}
+		if err != nil {
+			view.message = err.Error()
+		} else {
+			view.message = fmt.Sprintf("Watchpoint %d deleted", id)
+		}
+		break
+
+	case "q", "quit":
+		return true, nil
+
+	default:
+		view.message = fmt.Sprintf("Unknown command %q, try step, next, continue, back, break, delete, watch, unwatch or quit", fields[0])
+		break
+	}
+
+	return false, nil
+}
+
+// parseAddress reads a hexadecimal address, with or without a 0x prefix
+func parseAddress(text string) (uint16, error) {
+	text = strings.TrimPrefix(strings.ToLower(text), "0x")
+
+	address, err := strconv.ParseUint(text, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid address %q", text)
+	}
+
+	return uint16(address), nil
+}
+
+// draw redraws all of the panes
+func (view *debugView) draw() {
+	sys := view.dbg.System()
+	registers := sys.Registers()
+
+	width, height := termbox.Size()
+	view.x = view.display.width + 2
+	view.y = 0
+	if width < view.x + PANE_WIDTH {
+		view.x = 0
+		view.y = view.display.height + 2
+	}
+	for y := view.y; y < height; y++ {
+		for x := view.x; x < width; x++ {
+			termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+	view.row = view.y
+
+	view.heading("Registers")
+	for i := 0; i < chip8.REGISTER_COUNT; i += 4 {
+		view.line(fmt.Sprintf(
+			"V%X %02X  V%X %02X  V%X %02X  V%X %02X",
+			i, registers.V[i], i + 1, registers.V[i + 1], i + 2, registers.V[i + 2], i + 3, registers.V[i + 3],
+		))
+	}
+	view.line(fmt.Sprintf("I %04X  PC %04X  SP %X  DT %02X  ST %02X", registers.I, registers.PC, registers.SP, registers.DelayTimer, registers.SoundTimer))
+	view.line(fmt.Sprintf("Cycle %d", sys.Cycles()))
+	view.line("")
+
+	view.heading("Stack")
+	entries := strings.Fields(strings.Trim(sys.Stack(), "[]"))
+	for i := 0; i < len(entries); i += 8 {
+		end := i + 8
+		if end > len(entries) {
+			end = len(entries)
+		}
+		view.line(strings.Join(entries[i:end], " "))
+	}
+	view.line("")
+
+	view.heading("Memory at I")
+	view.memory(int(registers.I))
+	view.line("")
+
+	view.heading("Memory at PC")
+	view.memory(int(registers.PC))
+	view.line("")
+
+	view.heading("Code")
+	address := int(registers.PC)
+	for i := 0; i < CODE_LINES; i++ {
+		inst := sys.InstructionAt(address)
+
+		marker := "  "
+		if address == int(registers.PC) {
+			marker = "> "
+		}
+		if view.dbg.HasBreakpoint(uint16(address)) {
+			marker = marker[:1] + "*"
+		}
+
+		view.line(fmt.Sprintf("%s %04X  %04X  %s", marker, address, inst.Opcode, chip8.DescribeOp(inst, sys.Variant(), sys.Quirks())))
+		address += int(inst.Size())
+	}
+	view.line("")
+
+	breakpoints := []string{}
+	for _, address := range view.dbg.Breakpoints() {
+		breakpoints = append(breakpoints, fmt.Sprintf("%03X", address))
+	}
+	view.line("Breakpoints: " + strings.Join(breakpoints, " "))
+
+	watchpoints := []string{}
+	for _, watch := range view.dbg.Watchpoints() {
+		watchpoints = append(watchpoints, fmt.Sprintf("%d: %s", watch.ID, watch.Expression))
+	}
+	view.line("Watchpoints: " + strings.Join(watchpoints, ", "))
+	view.line("")
+
+	if view.dbg.Paused() {
+		view.line(view.dbg.Reason())
+	} else {
+		view.line("Running, F9 to pause")
+	}
+	view.line(view.message)
+	view.line(PROMPT + view.command + "_")
+
+	termbox.Flush()
+}
+
+// memory draws rows of bytes around address, highlighting it
+func (view *debugView) memory(address int) {
+	start := (address &^ 7) - 8
+	if start < 0 {
+		start = 0
+	}
+
+	sys := view.dbg.System()
+	for row := 0; row < MEMORY_ROWS; row++ {
+		rowStart := start + row * 8
+		data := sys.ReadMemory(rowStart, 8)
+
+		x := view.x
+		x = view.text(x, fmt.Sprintf("%04X:", rowStart), termbox.ColorDefault)
+		for i, value := range data {
+			attribute := termbox.ColorDefault
+			if rowStart + i == address {
+				attribute |= termbox.AttrReverse
+			}
+			x = view.text(x, " ", termbox.ColorDefault)
+			x = view.text(x, fmt.Sprintf("%02X", value), attribute)
+		}
+		view.row++
+	}
+}
+
+func (view *debugView) heading(text string) {
+	view.text(view.x, text, termbox.AttrBold)
+	view.row++
+}
+
+func (view *debugView) line(text string) {
+	view.text(view.x, text, termbox.ColorDefault)
+	view.row++
+}
+
+// text draws text on the current row starting at column x and returns the
+// column after it
+func (view *debugView) text(x int, text string, attribute termbox.Attribute) int {
+	width, _ := termbox.Size()
+	for _, ch := range text {
+		if x >= width {
+			break
+		}
+		termbox.SetCell(x, view.row, ch, attribute, termbox.ColorDefault)
+		x++
+	}
+
+	return x
+}
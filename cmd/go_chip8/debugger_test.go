@@ -0,0 +1,60 @@
+package main
+
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+func TestDebuggerCommands(t *testing.T) {
+	sys := chip8.NewSystem(chip8.WithVirtualClock(true), chip8.WithRewind(1 << 20))
+	sys.LoadFont()
+	err := sys.LoadROM([]byte{0x60, 0x01, 0x61, 0x02, 0x12, 0x04})
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := newDebugView(chip8.NewDebugger(sys), nil, "")
+
+	tests := []struct {
+		command string
+		// text the message has to start with
+		message string
+		pc uint16
+	}{
+		{"b 204", "Breakpoint set at 0x204", 0x200},
+		{"break 0x20x", "Invalid address", 0x200},
+		{"b", "Usage: b ADDRESS", 0x200},
+		{"s", "", 0x202},
+		{"step", "", 0x204},
+		{"back", "", 0x202},
+		{"d 0x204", "Breakpoint at 0x204 deleted", 0x202},
+		{"w V1", "Watchpoint 1 set on V1", 0x202},
+		{"n", "", 0x204},
+		{"unwatch 1", "Watchpoint 1 deleted", 0x204},
+		{"unwatch 1", "No watchpoint 1", 0x204},
+		{"frobnicate", "Unknown command \"frobnicate\"", 0x204},
+	}
+
+	for _, test := range tests {
+		quit, err := view.run(test.command)
+		if quit || err != nil {
+			t.Fatalf("%q quit: %v, error: %v", test.command, quit, err)
+		}
+		if !strings.HasPrefix(view.message, test.message) || (test.message == "" && view.message != "") {
+			t.Errorf("%q gave the message %q, expected %q", test.command, view.message, test.message)
+		}
+		if pc := sys.Registers().PC; pc != test.pc {
+			t.Errorf("PC is 0x%03X after %q, expected 0x%03X", pc, test.command, test.pc)
+		}
+	}
+
+	if view.dbg.HasBreakpoint(0x204) {
+		t.Errorf("Breakpoint was not deleted")
+	}
+	if quit, _ := view.run("quit"); !quit {
+		t.Errorf("quit did not quit")
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// register layout of the g and G packets, matching GDB_TARGET
+const GDB_REGISTERS_SIZE = chip8.REGISTER_COUNT + 2 + 2 + 1 + 1 + 1
+
+// GDB has no CHIP-8 architecture, so the registers are described to it
+var GDB_TARGET = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+<feature name="org.go_chip8.cpu">
+` + gdbRegisterTags() + `</feature>
+</target>
+`
+
+// interrupt byte sent by GDB while the target is running
+const GDB_INTERRUPT = "\x03"
+
+// stop signals
+const (
+	GDB_SIGINT = 2
+	GDB_SIGILL = 4
+	GDB_SIGTRAP = 5
+	GDB_SIGSEGV = 11
+)
+
+
+func gdbRegisterTags() string {
+	tags := ""
+	for i := 0; i < chip8.REGISTER_COUNT; i++ {
+		tags += fmt.Sprintf("<reg name=\"v%x\" bitsize=\"8\" type=\"uint8\"/>\n", i)
+	}
+	tags += "<reg name=\"i\" bitsize=\"16\" type=\"data_ptr\"/>\n"
+	tags += "<reg name=\"pc\" bitsize=\"16\" type=\"code_ptr\"/>\n"
+	tags += "<reg name=\"sp\" bitsize=\"8\" type=\"uint8\"/>\n"
+	tags += "<reg name=\"dt\" bitsize=\"8\" type=\"uint8\"/>\n"
+	tags += "<reg name=\"st\" bitsize=\"8\" type=\"uint8\"/>\n"
+
+	return tags
+}
+
+
+// gdbServer speaks the GDB remote serial protocol to a single debugger
+// connected over TCP
+type gdbServer struct {
+	dbg *chip8.Debugger
+	conn net.Conn
+
+	// guards writes to conn, which are made by the reader for acks
+	writeLock sync.Mutex
+
+	// received packets, and GDB_INTERRUPT for interrupts. Closed when the
+	// connection is.
+	packets chan string
+	// closed when serve returns, so the reader stops handing over packets
+	done chan bool
+
+	// watchpoint IDs of the addresses GDB watches
+	watches map[uint16]int
+}
+
+
+func newGDBServer(dbg *chip8.Debugger, conn net.Conn) *gdbServer {
+	server := new(gdbServer)
+	server.dbg = dbg
+	server.conn = conn
+	server.packets = make(chan string, 16)
+	server.done = make(chan bool)
+	server.watches = make(map[uint16]int)
+
+	return server
+}
+
+
+// runGDB waits for GDB to connect on port of localhost and lets it control
+// the system until it detaches or quit is signalled
+func runGDB(sys *chip8.System, port uint, quit <-chan bool) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	var conn net.Conn
+	select {
+	case <-quit:
+		sys.Quit()
+		return nil
+
+	case conn = <-accepted:
+		if conn == nil {
+			return fmt.Errorf("Error accepting GDB connection")
+		}
+	}
+	defer conn.Close()
+
+	server := newGDBServer(chip8.NewDebugger(sys), conn)
+	go server.read()
+
+	return server.serve(quit)
+}
+
+// serve handles packets, and runs the system at its clock speed while GDB
+// has it continue
+func (server *gdbServer) serve(quit <-chan bool) error {
+	sys := server.dbg.System()
+	defer close(server.done)
+
+	ticker := time.NewTicker(time.Second / time.Duration(sys.Clockspeed()))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			sys.Quit()
+			return nil
+
+		case packet, ok := <-server.packets:
+			if !ok {
+				return nil
+			}
+
+			done, err := server.handle(packet)
+			if done || err != nil {
+				return err
+			}
+
+		case <-ticker.C:
+			if server.dbg.Paused() {
+				continue
+			}
+
+			_, err := server.dbg.Tick()
+			if server.dbg.Paused() {
+				err = server.send(server.stopReply(err))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// read splits what GDB sends into packets, acknowledging each one
+func (server *gdbServer) read() {
+	defer close(server.packets)
+
+	reader := bufio.NewReader(server.conn)
+	for {
+		ch, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch ch {
+		case '$':
+			data, err := reader.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data) - 1]
+
+			checksum := make([]byte, 2)
+			_, err = reader.Read(checksum[:1])
+			if err == nil {
+				_, err = reader.Read(checksum[1:])
+			}
+			if err != nil {
+				return
+			}
+
+			if fmt.Sprintf("%02x", gdbChecksum(data)) != strings.ToLower(string(checksum)) {
+				server.write("-")
+				continue
+			}
+
+			server.write("+")
+			if !server.deliver(data) {
+				return
+			}
+			break
+
+		case GDB_INTERRUPT[0]:
+			if !server.deliver(GDB_INTERRUPT) {
+				return
+			}
+			break
+		}
+	}
+}
+
+// deliver hands a packet to serve, returning false once serve has returned
+func (server *gdbServer) deliver(packet string) bool {
+	select {
+	case server.packets <- packet:
+		return true
+
+	case <-server.done:
+		return false
+	}
+}
+
+func (server *gdbServer) write(data string) error {
+	server.writeLock.Lock()
+	defer server.writeLock.Unlock()
+
+	_, err := server.conn.Write([]byte(data))
+	return err
+}
+
+func (server *gdbServer) send(packet string) error {
+	return server.write(fmt.Sprintf("$%s#%02x", packet, gdbChecksum(packet)))
+}
+
+func gdbChecksum(data string) byte {
+	sum := byte(0)
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+
+	return sum
+}
+
+// handle answers a packet, returning whether the session is over
+func (server *gdbServer) handle(packet string) (bool, error) {
+	if packet == GDB_INTERRUPT {
+		if server.dbg.Paused() {
+			return false, nil
+		}
+
+		server.dbg.Pause("Interrupted")
+		return false, server.send(fmt.Sprintf("S%02x", GDB_SIGINT))
+	}
+
+	if packet == "" {
+		return false, server.send("")
+	}
+
+	dbg := server.dbg
+	sys := dbg.System()
+	args := packet[1:]
+
+	switch packet[0] {
+	case '?':
+		return false, server.send(fmt.Sprintf("S%02x", GDB_SIGTRAP))
+
+	case 'q':
+		return false, server.send(server.query(args))
+
+	case 'H':
+		return false, server.send("OK")
+
+	case 'g':
+		return false, server.send(hex.EncodeToString(gdbRegisters(sys.Registers())))
+
+	case 'G':
+		data, err := hex.DecodeString(args)
+		if err != nil || len(data) != GDB_REGISTERS_SIZE {
+			return false, server.send("E01")
+		}
+
+		return false, server.send(gdbResult(sys.SetRegisters(gdbSetRegisters(sys.Registers(), data))))
+
+	case 'p':
+		number, err := strconv.ParseUint(args, 16, 8)
+		offset, size := gdbRegisterRange(int(number))
+		if err != nil || size == 0 {
+			return false, server.send("E01")
+		}
+
+		return false, server.send(hex.EncodeToString(gdbRegisters(sys.Registers())[offset:offset + size]))
+
+	case 'P':
+		parts := strings.SplitN(args, "=", 2)
+		if len(parts) != 2 {
+			return false, server.send("E01")
+		}
+
+		number, err := strconv.ParseUint(parts[0], 16, 8)
+		offset, size := gdbRegisterRange(int(number))
+		value, valueErr := hex.DecodeString(parts[1])
+		if err != nil || valueErr != nil || size == 0 || len(value) != size {
+			return false, server.send("E01")
+		}
+
+		data := gdbRegisters(sys.Registers())
+		copy(data[offset:], value)
+		return false, server.send(gdbResult(sys.SetRegisters(gdbSetRegisters(sys.Registers(), data))))
+
+	case 'm':
+		address, length, err := gdbRange(args)
+		if err != nil || address >= sys.MemorySize() {
+			return false, server.send("E01")
+		}
+		if address + length > sys.MemorySize() {
+			length = sys.MemorySize() - address
+		}
+
+		return false, server.send(hex.EncodeToString(sys.ReadMemory(address, length)))
+
+	case 'M':
+		parts := strings.SplitN(args, ":", 2)
+		if len(parts) != 2 {
+			return false, server.send("E01")
+		}
+
+		address, length, err := gdbRange(parts[0])
+		data, dataErr := hex.DecodeString(parts[1])
+		if err != nil || dataErr != nil || len(data) != length {
+			return false, server.send("E01")
+		}
+
+		return false, server.send(gdbResult(sys.WriteMemory(address, data)))
+
+	case 'c', 's':
+		if args != "" {
+			address, err := strconv.ParseUint(args, 16, 16)
+			if err != nil {
+				return false, server.send("E01")
+			}
+
+			registers := sys.Registers()
+			registers.PC = uint16(address)
+			sys.SetRegisters(registers)
+		}
+
+		if packet[0] == 'c' {
+			dbg.Continue()
+			return false, nil
+		}
+
+		_, err := dbg.StepInto()
+		return false, server.send(server.stopReply(err))
+
+	case 'Z', 'z':
+		return false, server.send(server.point(packet[0] == 'Z', args))
+
+	case 'D':
+		server.send("OK")
+		return true, nil
+
+	case 'k':
+		server.dbg.System().Quit()
+		return true, nil
+	}
+
+	// unsupported packets get an empty reply
+	return false, server.send("")
+}
+
+func (server *gdbServer) query(query string) string {
+	switch {
+	case strings.HasPrefix(query, "Supported"):
+		return "PacketSize=1000;qXfer:features:read+"
+
+	case strings.HasPrefix(query, "Xfer:features:read:target.xml:"):
+		address, length, err := gdbRange(strings.TrimPrefix(query, "Xfer:features:read:target.xml:"))
+		if err != nil {
+			return "E01"
+		}
+
+		if address >= len(GDB_TARGET) {
+			return "l"
+		}
+		if address + length >= len(GDB_TARGET) {
+			return "l" + GDB_TARGET[address:]
+		}
+		return "m" + GDB_TARGET[address:address + length]
+
+	case query == "Attached":
+		return "1"
+
+	case query == "C":
+		return "QC1"
+
+	case query == "fThreadInfo":
+		return "m1"
+
+	case query == "sThreadInfo":
+		return "l"
+	}
+
+	return ""
+}
+
+// point sets or removes a breakpoint (Z0 and Z1) or a write watchpoint (Z2)
+func (server *gdbServer) point(insert bool, args string) string {
+	parts := strings.SplitN(args, ",", 3)
+	if len(parts) != 3 {
+		return "E01"
+	}
+
+	address, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(parts[2], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+
+	switch parts[0] {
+	case "0", "1":
+		if insert {
+			server.dbg.SetBreakpoint(uint16(address))
+		} else {
+			server.dbg.ClearBreakpoint(uint16(address))
+		}
+		return "OK"
+
+	case "2":
+		for i := uint64(0); i < length; i++ {
+			watched := uint16(address + i)
+			id, ok := server.watches[watched]
+
+			if insert && !ok {
+				id, err = server.dbg.Watch(fmt.Sprintf("%X", watched))
+				if err != nil {
+					return "E01"
+				}
+				server.watches[watched] = id
+			} else if !insert && ok {
+				server.dbg.Unwatch(id)
+				delete(server.watches, watched)
+			}
+		}
+		return "OK"
+	}
+
+	return ""
+}
+
+// stopReply tells GDB why the system stopped
+func (server *gdbServer) stopReply(err error) string {
+	sys := server.dbg.System()
+
+	switch sys.HaltReason() {
+	case chip8.HALT_FAULT:
+		if _, ok := sys.Fault().(*chip8.MemoryFault); ok {
+			return fmt.Sprintf("S%02x", GDB_SIGSEGV)
+		}
+		return fmt.Sprintf("S%02x", GDB_SIGILL)
+
+	case chip8.HALT_EXIT, chip8.HALT_QUIT:
+		return "W00"
+	}
+	if err != nil {
+		return fmt.Sprintf("S%02x", GDB_SIGILL)
+	}
+
+	hit, ok := server.dbg.LastHit()
+	if ok && hit.Watchpoint.Kind == chip8.WATCH_MEMORY {
+		return fmt.Sprintf("T%02xwatch:%x;", GDB_SIGTRAP, hit.Watchpoint.Address)
+	}
+
+	return fmt.Sprintf("S%02x", GDB_SIGTRAP)
+}
+
+func gdbResult(err error) string {
+	if err != nil {
+		return "E01"
+	}
+
+	return "OK"
+}
+
+// gdbRange parses the ADDR,LENGTH arguments of memory packets
+func gdbRange(args string) (int, int, error) {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid range %q", args)
+	}
+
+	address, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(address), int(length), nil
+}
+
+// gdbRegisters lays out the registers as in the g packet, multi-byte ones in
+// little endian
+func gdbRegisters(registers chip8.Registers) []byte {
+	data := make([]byte, 0, GDB_REGISTERS_SIZE)
+	data = append(data, registers.V[:]...)
+	data = append(data, byte(registers.I), byte(registers.I >> 8))
+	data = append(data, byte(registers.PC), byte(registers.PC >> 8))
+	data = append(data, byte(registers.SP), registers.DelayTimer, registers.SoundTimer)
+
+	return data
+}
+
+func gdbSetRegisters(registers chip8.Registers, data []byte) chip8.Registers {
+	copy(registers.V[:], data)
+	data = data[chip8.REGISTER_COUNT:]
+	registers.I = uint16(data[0]) | uint16(data[1]) << 8
+	registers.PC = uint16(data[2]) | uint16(data[3]) << 8
+	registers.SP = uint(data[4])
+	registers.DelayTimer = data[5]
+	registers.SoundTimer = data[6]
+
+	return registers
+}
+
+// gdbRegisterRange is where register number is in the g packet, with a size
+// of 0 for unknown registers
+func gdbRegisterRange(number int) (int, int) {
+	switch {
+	case number < chip8.REGISTER_COUNT:
+		return number, 1
+	case number == chip8.REGISTER_COUNT:
+		return chip8.REGISTER_COUNT, 2
+	case number == chip8.REGISTER_COUNT + 1:
+		return chip8.REGISTER_COUNT + 2, 2
+	case number < chip8.REGISTER_COUNT + 5:
+		// after the two bytes each of I and PC
+		return number + 2, 1
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,168 @@
+package main
+
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// gdbClient plays GDB on one end of a pipe to a gdbServer
+type gdbClient struct {
+	t *testing.T
+	conn net.Conn
+	reader *bufio.Reader
+}
+
+
+// startGDB serves a system running rom over a pipe, the returned channels
+// are closed once serve and read return
+func startGDB(t *testing.T, rom []byte) (*gdbClient, chan bool, chan bool) {
+	t.Helper()
+
+	sys := chip8.NewSystem(chip8.WithVirtualClock(true))
+	sys.LoadFont()
+	err := sys.LoadROM(rom)
+	if err != nil {
+		t.Fatalf("Error loading ROM: %v", err)
+	}
+
+	conn, serverConn := net.Pipe()
+	server := newGDBServer(chip8.NewDebugger(sys), serverConn)
+
+	served := make(chan bool)
+	read := make(chan bool)
+	go func() {
+		server.read()
+		close(read)
+	}()
+	go func() {
+		server.serve(make(chan bool))
+		close(served)
+	}()
+
+	client := &gdbClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	return client, served, read
+}
+
+// send writes a packet and waits for its acknowledgement
+func (client *gdbClient) send(packet string) {
+	client.t.Helper()
+
+	fmt.Fprintf(client.conn, "$%s#%02x", packet, gdbChecksum(packet))
+	ack, err := client.reader.ReadByte()
+	if err != nil || ack != '+' {
+		client.t.Fatalf("Packet %q was not acknowledged: %q %v", packet, ack, err)
+	}
+}
+
+// receive reads the next packet
+func (client *gdbClient) receive() string {
+	client.t.Helper()
+
+	client.conn.SetReadDeadline(time.Now().Add(time.Second))
+	defer client.conn.SetReadDeadline(time.Time{})
+
+	start, err := client.reader.ReadString('$')
+	if err != nil || start != "$" {
+		client.t.Fatalf("Expected a packet, got %q: %v", start, err)
+	}
+	data, err := client.reader.ReadString('#')
+	if err != nil {
+		client.t.Fatal(err)
+	}
+	client.reader.Discard(2)
+
+	return strings.TrimSuffix(data, "#")
+}
+
+func (client *gdbClient) exchange(packet string) string {
+	client.t.Helper()
+
+	client.send(packet)
+	return client.receive()
+}
+
+
+func TestGDBPackets(t *testing.T) {
+	// V0 = 0x12, I = 0x300, store V0, then loop
+	rom := []byte{0x60, 0x12, 0xA3, 0x00, 0xF0, 0x55, 0x12, 0x06}
+	client, served, _ := startGDB(t, rom)
+	defer client.conn.Close()
+
+	tests := []struct {
+		packet string
+		reply string
+	}{
+		{"?", "S05"},
+		{"qSupported:xmlRegisters=i386", "PacketSize=1000;qXfer:features:read+"},
+		{"qAttached", "1"},
+		{"m200,4", "6012a300"},
+		{"m0fff,4", "00"},
+		{"m1000,1", "E01"},
+		{"M300,2:abcd", "OK"},
+		{"m300,2", "abcd"},
+		{"M300,2:ab", "E01"},
+		{"p11", "0002"},
+		{"p15", "E01"},
+		{"P0=42", "OK"},
+		{"p0", "42"},
+		{"s", "S05"},
+		{"p0", "12"},
+		{"Z2,300,1", "OK"},
+		{"Z0,206,2", "OK"},
+		{"s", "S05"},
+		{"s", "T05watch:300;"},
+		{"m300,1", "12"},
+		{"z2,300,1", "OK"},
+		{"vMustReplyEmpty", ""},
+	}
+	for _, test := range tests {
+		if reply := client.exchange(test.packet); reply != test.reply {
+			t.Errorf("Got %q for %q, expected %q", reply, test.packet, test.reply)
+		}
+	}
+
+	// continuing runs into the breakpoint at the loop
+	client.send("c")
+	if reply := client.receive(); reply != "S05" {
+		t.Errorf("Got %q after continuing, expected S05", reply)
+	}
+	if reply := client.exchange("p11"); reply != "0602" {
+		t.Errorf("Stopped at %q, expected 0602", reply)
+	}
+
+	if reply := client.exchange("D"); reply != "OK" {
+		t.Errorf("Got %q for detaching, expected OK", reply)
+	}
+	select {
+	case <-served:
+		break
+	case <-time.After(time.Second):
+		t.Errorf("Still serving after detaching")
+	}
+}
+
+func TestGDBReaderStopsAfterDetaching(t *testing.T) {
+	client, served, read := startGDB(t, []byte{0x12, 0x00})
+	defer client.conn.Close()
+
+	client.exchange("D")
+	<-served
+
+	// more interrupts than the reader can hand over without serve
+	go client.conn.Write([]byte(strings.Repeat(GDB_INTERRUPT, 64)))
+
+	select {
+	case <-read:
+		break
+	case <-time.After(time.Second):
+		t.Errorf("The reader is still blocked after serve returned")
+	}
+}
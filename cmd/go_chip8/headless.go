@@ -0,0 +1,31 @@
+package main
+
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// characters for the four values an XO-CHIP pixel can have
+var PIXELS = []rune{'.', '█', '▒', '▓'}
+
+
+// runHeadless runs the system for the given number of frames without a
+// terminal and writes the final framebuffer to out
+func runHeadless(sys *chip8.System, frames uint64, out io.Writer) error {
+	err := sys.RunFrames(frames)
+
+	writer := bufio.NewWriter(out)
+	for _, row := range sys.Framebuffer() {
+		for _, pixel := range row {
+			writer.WriteRune(PIXELS[pixel & 0x3])
+		}
+		writer.WriteRune('\n')
+	}
+	writer.Flush()
+
+	return err
+}
@@ -0,0 +1,312 @@
+package main
+
+
+import (
+	"fmt"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+	"github.com/nsf/termbox-go"
+)
+
+
+// exit statuses besides 0, for a program that exited or ran its frames
+const (
+	// bad arguments, or a ROM that could not be loaded or run
+	EXIT_ERROR = 1
+	// an instruction of the ROM failed
+	EXIT_FAULT = 2
+	// the user quit before the program exited
+	EXIT_QUIT = 130
+)
+
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "assemble" {
+		os.Exit(runAssemble(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "analyze" {
+		os.Exit(runAnalyze(os.Args[2:]))
+	}
+
+	var clockspeed uint64
+	var disassemble bool
+	var asm bool
+	var cfg bool
+	var debug bool
+	var rom string
+	var keyTimeOut uint
+	var headless bool
+	var frames uint64
+	var seed int64
+	var variantName string
+	var quirksName string
+	var rewindBudget uint
+	var gdbPort uint
+	var dap bool
+	var tracePath string
+	var traceFormat string
+	var traceAddresses string
+	var traceCycles string
+	var wrapMemory bool
+	var wavPath string
+	var player string
+
+	flag.Uint64Var(&clockspeed, "clockspeed", 500, "Clockspeed in Hz")
+	flag.BoolVar(&debug, "debug", false, "Run the ROM in the debugger")
+	flag.BoolVar(&disassemble, "disassemble", false, "Disassemble ROM")
+	flag.BoolVar(&asm, "asm", false, "With --disassemble, print assembler source that assembles back to the ROM")
+	flag.BoolVar(&cfg, "cfg", false, "Print the control flow graph of every subroutine in Graphviz DOT format")
+	flag.StringVar(&rom, "rom", "", "ROM to run")
+	flag.UintVar(&keyTimeOut, "keytimeout", 100, "Key presses are held this amount of milliseconds")
+	flag.BoolVar(&headless, "headless", false, "Run without a terminal for --frames frames and print the display")
+	flag.Uint64Var(&frames, "frames", 600, "Number of frames to run in headless mode")
+	flag.Int64Var(&seed, "seed", 0, "Seed for the random number generator, defaults to the current time")
+	flag.StringVar(&variantName, "variant", "chip8", "Platform to emulate, chip8, schip or xochip")
+	flag.StringVar(&quirksName, "quirks", "", "Quirk profile, one of chip8, vip, chip48, schip or xochip, defaults to the one of the variant")
+	flag.UintVar(&rewindBudget, "rewind", 16, "Memory in MiB kept for rewinding with Backspace, 0 disables rewinding")
+	flag.UintVar(&gdbPort, "gdb", 0, "Wait for GDB to connect on this port of localhost and let it control the ROM")
+	flag.BoolVar(&dap, "dap", false, "Serve the Debug Adapter Protocol on stdin and stdout, the ROM is given by the launch request")
+	flag.StringVar(&tracePath, "trace", "", "Write every executed instruction to this file")
+	flag.StringVar(&traceFormat, "traceformat", "text", "Format of the trace, text or jsonl")
+	flag.StringVar(&traceAddresses, "traceaddresses", "", "Only trace instructions at these addresses, as hexadecimal FROM-TO")
+	flag.StringVar(&traceCycles, "tracecycles", "", "Only trace these instructions, counted from 0, as FROM-TO")
+	flag.StringVar(&wavPath, "wav", "", "Record the sound into this WAV file")
+	flag.StringVar(&player, "player", "", "Play the sound live by piping 8 bit unsigned mono PCM at 44100 Hz into this command, such as \"aplay -q -f U8 -r 44100\"")
+	flag.BoolVar(&wrapMemory, "wrapmemory", false, "Wrap addresses outside of memory and keys above 0xF around instead of stopping the ROM")
+	flag.Parse()
+
+	if rom == "" && !dap {
+		fmt.Println("Please supply a ROM")
+		os.Exit(1)
+	}
+
+	// with --dap stdout carries the protocol, so errors go to stderr
+	var errorOutput io.Writer = os.Stdout
+	if dap {
+		errorOutput = os.Stderr
+	}
+
+	variant, err := chip8.ParseVariant(variantName)
+	if err != nil {
+		fmt.Fprintln(errorOutput, err)
+		os.Exit(1)
+	}
+
+	quirks := chip8.DefaultQuirks(variant)
+	if quirksName != "" {
+		quirks, err = chip8.ParseQuirks(quirksName)
+		if err != nil {
+			fmt.Fprintln(errorOutput, err)
+			os.Exit(1)
+		}
+	}
+
+	if disassemble || cfg {
+		sys := chip8.NewSystem(chip8.WithVariant(variant), chip8.WithQuirks(quirks))
+		sys.LoadFont()
+		err := sys.LoadROMFile(rom)
+		if err != nil {
+			fmt.Printf("Error loading ROM: %v\n", err)
+			os.Exit(1)
+		}
+
+		if cfg {
+			err = chip8.FollowFlow(sys.ROM(), variant).WriteDOT(os.Stdout, quirks)
+			if err != nil {
+				fmt.Printf("Error writing control flow graph: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if asm {
+			err = chip8.NewListing(sys.ROM(), variant).Write(os.Stdout)
+			if err != nil {
+				fmt.Printf("Error writing disassembly: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		sys.Disassemble(os.Stdout)
+		return
+	}
+
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) {
+		seedSet = seedSet || f.Name == "seed"
+	})
+	if !seedSet {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Fprintf(os.Stderr, "Seed: %d\n", seed)
+
+	options := []chip8.Option{
+		chip8.WithClockspeed(clockspeed),
+		chip8.WithDebug(debug),
+		chip8.WithSeed(seed),
+		chip8.WithVariant(variant),
+		chip8.WithQuirks(quirks),
+		chip8.WithWrapMemory(wrapMemory),
+	}
+
+	// os.Exit skips deferred calls, so the trace is closed by exit
+	exit := os.Exit
+	if tracePath != "" {
+		tracer, closeTrace, err := openTrace(tracePath, traceFormat, traceAddresses, traceCycles)
+		if err != nil {
+			fmt.Fprintf(errorOutput, "Error opening trace: %v\n", err)
+			os.Exit(1)
+		}
+		options = append(options, chip8.WithTrace(tracer))
+
+		exit = func(code int) {
+			err := closeTrace()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing trace: %v\n", err)
+				code = EXIT_ERROR
+			}
+			os.Exit(code)
+		}
+	}
+
+	audio, closeAudio, err := openAudio(wavPath, player)
+	if err != nil {
+		fmt.Fprintf(errorOutput, "Error opening audio: %v\n", err)
+		exit(1)
+	}
+	if audio != nil {
+		options = append(options, chip8.WithAudio(audio))
+	}
+
+	exitTrace := exit
+	exit = func(code int) {
+		err := closeAudio()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing audio: %v\n", err)
+			code = EXIT_ERROR
+		}
+		exitTrace(code)
+	}
+
+	if dap {
+		err := runDAP(append(options, chip8.WithVirtualClock(true)), os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error serving DAP: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if debug || gdbPort != 0 {
+		// timers only run along with instructions, so they stop while paused
+		options = append(options, chip8.WithVirtualClock(true))
+	}
+
+	if headless {
+		sys := chip8.NewSystem(append(options, chip8.WithVirtualClock(true))...)
+
+		sys.LoadFont()
+		err := sys.LoadROMFile(rom)
+		if err != nil {
+			fmt.Printf("Error loading ROM: %v\n", err)
+			exit(1)
+		}
+
+		if gdbPort != 0 {
+			fmt.Fprintf(os.Stderr, "Waiting for GDB on localhost:%d\n", gdbPort)
+			err = runGDB(sys, gdbPort, nil)
+			if err == nil {
+				// show the final screen
+				err = runHeadless(sys, 0, os.Stdout)
+			}
+		} else {
+			err = runHeadless(sys, frames, os.Stdout)
+		}
+		if err != nil {
+			printError(sys, err)
+		}
+		exit(exitStatus(sys, err))
+	}
+
+	err = termbox.Init()
+	if err != nil {
+		fmt.Printf("Error initializing termbox: %v\n", err)
+		exit(1)
+	}
+	termbox.HideCursor()
+
+	input := newTermboxInput(keyTimeOut)
+	display := newTermboxDisplay()
+	options = append(options, chip8.WithRewind(int(rewindBudget) << 20))
+	if audio == nil {
+		options = append(options, chip8.WithAudio(new(termboxBell)))
+	}
+	sys := chip8.NewSystem(append(options, chip8.WithDisplay(display), chip8.WithInput(input))...)
+	sys.LoadFont()
+	err = sys.LoadROMFile(rom)
+	if err != nil {
+		termbox.Close()
+		fmt.Printf("Error loading ROM: %v\n", err)
+		exit(1)
+	}
+
+	input.poll()
+
+	var runErr error
+	if gdbPort != 0 {
+		display.status(fmt.Sprintf("Waiting for GDB on localhost:%d", gdbPort))
+		runErr = runGDB(sys, gdbPort, input.quit)
+	} else if debug {
+		runErr = runDebugger(sys, input, display, rom)
+	} else {
+		runErr = runTerminal(sys, input, display, rom)
+	}
+
+	for i, ch := range "Press any key to quit" {
+		termbox.SetCell(i, 0, ch, termbox.ColorDefault, termbox.ColorDefault)
+	}
+	termbox.Flush()
+
+	input.wait()
+
+	termbox.Close()
+
+	if runErr == nil {
+		runErr = sys.Fault()
+	}
+	if runErr != nil {
+		printError(sys, runErr)
+	}
+	exit(exitStatus(sys, runErr))
+}
+
+// exitStatus tells how the machine stopped, given the error it stopped with
+func exitStatus(sys *chip8.System, err error) int {
+	switch {
+	case sys.HaltReason() == chip8.HALT_FAULT:
+		return EXIT_FAULT
+	case err != nil:
+		return EXIT_ERROR
+	case sys.HaltReason() == chip8.HALT_QUIT:
+		return EXIT_QUIT
+	}
+
+	return 0
+}
+
+// printError reports the error that stopped the ROM, along with the state of
+// the machine when the ROM itself faulted
+func printError(sys *chip8.System, err error) {
+	if sys.HaltReason() != chip8.HALT_FAULT {
+		fmt.Printf("Stopped: %v (seed %d)\n", err, sys.Seed())
+		return
+	}
+
+	fmt.Printf("Stopped by a fault: %v (seed %d)\n", err, sys.Seed())
+	fmt.Println(sys.Registers())
+}
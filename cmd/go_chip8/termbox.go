@@ -0,0 +1,216 @@
+package main
+
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+	"github.com/nsf/termbox-go"
+)
+
+
+var INPUT_MAP = map[rune]byte{
+	'1': 0x1,
+	'2': 0x2,
+	'3': 0x3,
+	'4': 0xC,
+	'q': 0x4,
+	'w': 0x5,
+	'e': 0x6,
+	'r': 0xD,
+	'a': 0x7,
+	's': 0x8,
+	'd': 0x9,
+	'f': 0xE,
+	'z': 0xA,
+	'x': 0x0,
+	'c': 0xB,
+	'v': 0xF,
+}
+
+
+// colours of the four values an XO-CHIP pixel can have
+var PALETTE = []termbox.Attribute{
+	termbox.ColorDefault,
+	termbox.ColorDefault,
+	termbox.ColorRed,
+	termbox.ColorYellow,
+}
+
+
+// termboxDisplay renders the framebuffer into the terminal, one cell per pixel
+type termboxDisplay struct {
+	width int
+	height int
+}
+
+
+func newTermboxDisplay() *termboxDisplay {
+	return new(termboxDisplay)
+}
+
+
+func (display *termboxDisplay) Present(frame [][]byte) error {
+	for y := 0; y < len(frame); y++ {
+		for x := 0; x < len(frame[y]); x++ {
+			if frame[y][x] != 0 {
+				termbox.SetCell(x, y, '█', PALETTE[frame[y][x] & 0x3], termbox.ColorDefault)
+			} else {
+				termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+			}
+		}
+	}
+
+	return termbox.Flush()
+}
+
+func (display *termboxDisplay) Clear() error {
+	err := termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	if err != nil {
+		return err
+	}
+
+	return termbox.Flush()
+}
+
+func (display *termboxDisplay) Resize(width int, height int) error {
+	display.width = width
+	display.height = height
+
+	return display.Clear()
+}
+
+// status shows a message on the line below the framebuffer
+func (display *termboxDisplay) status(message string) {
+	width, _ := termbox.Size()
+	runes := []rune(message)
+	for i := 0; i < width; i++ {
+		ch := ' '
+		if i < len(runes) {
+			ch = runes[i]
+		}
+		termbox.SetCell(i, display.height, ch, termbox.ColorDefault, termbox.ColorDefault)
+	}
+
+	termbox.Flush()
+}
+
+
+// termboxInput polls the terminal for key presses. A terminal can't report
+// key releases, so every press is released again after keyTimeOut.
+type termboxInput struct {
+	*chip8.QueueInput
+
+	keyTimeOut time.Duration
+	keyTimers []*time.Timer
+	// guards releases of timers which were already replaced, and muted
+	timerLock sync.Mutex
+	// key presses are not passed on to the system, such as while the
+	// debugger's command line is in use
+	muted bool
+
+	// signalled on Ctrl-C
+	quit chan bool
+
+	// every key event is also forwarded here for the frontend, dropped if
+	// nobody is listening
+	keys chan termbox.Event
+}
+
+
+func newTermboxInput(keyTimeOut uint) *termboxInput {
+	input := new(termboxInput)
+	input.QueueInput = chip8.NewQueueInput()
+	input.keyTimeOut = time.Duration(keyTimeOut) * time.Millisecond
+	input.keyTimers = make([]*time.Timer, chip8.KEY_COUNT)
+	input.quit = make(chan bool, 1)
+	input.keys = make(chan termbox.Event, 16)
+
+	return input
+}
+
+
+// run in a goroutine
+func (input *termboxInput) poll() {
+	go func() {
+		for {
+			ev := termbox.PollEvent()
+			if ev.Type != termbox.EventKey {
+				continue
+			}
+
+			if ev.Key == termbox.KeyCtrlC {
+				select {
+				case input.quit <- true:
+				default:
+				}
+				continue
+			}
+
+			select {
+			case input.keys <- ev:
+			default:
+			}
+
+			mappedKey, ok := INPUT_MAP[ev.Ch]
+			if ok {
+				input.hold(mappedKey)
+			}
+		}
+	}()
+}
+
+func (input *termboxInput) hold(key byte) {
+	input.timerLock.Lock()
+	defer input.timerLock.Unlock()
+
+	if input.muted {
+		return
+	}
+
+	if input.keyTimers[key] != nil {
+		input.keyTimers[key].Stop()
+	}
+
+	input.Press(key)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(input.keyTimeOut, func() {
+		input.timerLock.Lock()
+		defer input.timerLock.Unlock()
+
+		// a newer press of the same key owns the release now
+		if input.keyTimers[key] != timer {
+			return
+		}
+
+		input.keyTimers[key] = nil
+		input.Release(key)
+	})
+	input.keyTimers[key] = timer
+}
+
+// mute stops passing key presses on to the system until it is unmuted
+func (input *termboxInput) mute(muted bool) {
+	input.timerLock.Lock()
+	defer input.timerLock.Unlock()
+
+	input.muted = muted
+}
+
+// wait blocks until any key is pressed
+func (input *termboxInput) wait() {
+	for {
+		select {
+		case <-input.keys:
+		case <-input.quit:
+		default:
+			select {
+			case <-input.keys:
+			case <-input.quit:
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jwoos/go_chip8/chip8"
+	"github.com/nsf/termbox-go"
+)
+
+
+// hotkeys to save to and load from the numbered save slots
+var SAVE_KEYS = map[termbox.Key]int{
+	termbox.KeyF1: 1,
+	termbox.KeyF2: 2,
+	termbox.KeyF3: 3,
+	termbox.KeyF4: 4,
+}
+
+var LOAD_KEYS = map[termbox.Key]int{
+	termbox.KeyF5: 1,
+	termbox.KeyF6: 2,
+	termbox.KeyF7: 3,
+	termbox.KeyF8: 4,
+}
+
+// number of frames a press of the rewind key goes back
+const REWIND_FRAMES = 30
+
+
+// runTerminal runs the system at its clock speed until the program halts, an
+// instruction fails or Ctrl-C is pressed. Hotkeys are handled in between
+// instructions.
+func runTerminal(sys *chip8.System, input *termboxInput, display *termboxDisplay, rom string) error {
+	ticker := time.NewTicker(time.Second / time.Duration(sys.Clockspeed()))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-input.quit:
+			sys.Quit()
+			return nil
+
+		case ev := <-input.keys:
+			hotkey(sys, display, rom, ev)
+
+		case <-ticker.C:
+			_, err := sys.Step()
+			if err != nil {
+				return err
+			}
+
+			if sys.Halted() {
+				return nil
+			}
+		}
+	}
+}
+
+// hotkey handles the save, load and rewind keys and reports whether ev was
+// one of them
+func hotkey(sys *chip8.System, display *termboxDisplay, rom string, ev termbox.Event) bool {
+	if slot, ok := SAVE_KEYS[ev.Key]; ok {
+		err := saveSlot(sys, rom, slot)
+		if err != nil {
+			display.status(fmt.Sprintf("Error saving slot %d: %v", slot, err))
+		} else {
+			display.status(fmt.Sprintf("Saved slot %d", slot))
+		}
+		return true
+	}
+
+	if slot, ok := LOAD_KEYS[ev.Key]; ok {
+		err := loadSlot(sys, rom, slot)
+		if err != nil {
+			display.status(fmt.Sprintf("Error loading slot %d: %v", slot, err))
+		} else {
+			display.status(fmt.Sprintf("Loaded slot %d", slot))
+		}
+		return true
+	}
+
+	if ev.Key == termbox.KeyBackspace || ev.Key == termbox.KeyBackspace2 {
+		display.status(rewind(sys))
+		return true
+	}
+
+	return false
+}
+
+// rewind steps the system back by REWIND_FRAMES frames, or as far as the
+// history goes, and describes the outcome
+func rewind(sys *chip8.System) string {
+	steps := REWIND_FRAMES * sys.CyclesPerFrame()
+	for i := uint64(0); i < steps; i++ {
+		err := sys.StepBack()
+		if err == chip8.ErrNoHistory {
+			if i == 0 {
+				return "Nothing to rewind"
+			}
+			return "Rewound to the start of the history"
+		}
+		if err != nil {
+			return fmt.Sprintf("Error rewinding: %v", err)
+		}
+	}
+
+	return fmt.Sprintf("Rewound %d frames", REWIND_FRAMES)
+}
+
+func slotPath(rom string, slot int) string {
+	return fmt.Sprintf("%s.state%d", rom, slot)
+}
+
+func saveSlot(sys *chip8.System, rom string, slot int) error {
+	file, err := os.Create(slotPath(rom, slot))
+	if err != nil {
+		return err
+	}
+
+	err = sys.SaveState(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func loadSlot(sys *chip8.System, rom string, slot int) error {
+	file, err := os.Open(slotPath(rom, slot))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return sys.LoadState(file)
+}
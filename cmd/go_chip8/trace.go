@@ -0,0 +1,92 @@
+package main
+
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jwoos/go_chip8/chip8"
+)
+
+
+// openTrace creates a tracer writing to path. Address ranges are given in
+// hexadecimal and cycle ranges in decimal, both as FROM-TO where either end
+// can be left out. The returned function flushes and closes the trace.
+func openTrace(path string, formatName string, addresses string, cycles string) (*chip8.Tracer, func() error, error) {
+	format, err := chip8.ParseTraceFormat(formatName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addressFrom, addressTo, err := parseRange(addresses, 16, 0xFFFF)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cycleFrom, cycleTo, err := parseRange(cycles, 10, ^uint64(0))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracer := chip8.NewTracer(file, format)
+	tracer.FilterAddresses(uint16(addressFrom), uint16(addressTo))
+	tracer.FilterCycles(cycleFrom, cycleTo)
+
+	closeTrace := func() error {
+		err := tracer.Flush()
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+
+		return err
+	}
+
+	return tracer, closeTrace, nil
+}
+
+// parseRange reads an inclusive FROM-TO range, defaulting to 0 and max
+func parseRange(text string, base int, max uint64) (uint64, uint64, error) {
+	if text == "" {
+		return 0, max, nil
+	}
+
+	parts := strings.SplitN(text, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("Invalid range %q, expected FROM-TO", text)
+	}
+
+	bits := 64
+	if max <= 0xFFFF {
+		bits = 16
+	}
+
+	from := uint64(0)
+	to := max
+	var err error
+	if parts[0] != "" {
+		from, err = strconv.ParseUint(strings.TrimPrefix(strings.ToLower(parts[0]), "0x"), base, bits)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid range %q", text)
+		}
+	}
+	if parts[1] != "" {
+		to, err = strconv.ParseUint(strings.TrimPrefix(strings.ToLower(parts[1]), "0x"), base, bits)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid range %q", text)
+		}
+	}
+
+	if from > to {
+		return 0, 0, fmt.Errorf("Invalid range %q, it ends before it starts", text)
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+
+import (
+	"testing"
+)
+
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		text string
+		base int
+		max uint64
+		from uint64
+		to uint64
+		valid bool
+	}{
+		{"", 16, 0xFFFF, 0, 0xFFFF, true},
+		{"200-2FF", 16, 0xFFFF, 0x200, 0x2FF, true},
+		{"0x200-0x2ff", 16, 0xFFFF, 0x200, 0x2FF, true},
+		{"300-", 16, 0xFFFF, 0x300, 0xFFFF, true},
+		{"-300", 16, 0xFFFF, 0, 0x300, true},
+		{"100-200", 10, ^uint64(0), 100, 200, true},
+		{"10000-10001", 16, 0xFFFF, 0, 0, false},
+		{"2FF-200", 16, 0xFFFF, 0, 0, false},
+		{"200", 16, 0xFFFF, 0, 0, false},
+		{"1A-20", 10, ^uint64(0), 0, 0, false},
+	}
+
+	for _, test := range tests {
+		from, to, err := parseRange(test.text, test.base, test.max)
+		if (err == nil) != test.valid {
+			t.Errorf("Parsing %q gave the error %v", test.text, err)
+			continue
+		}
+		if from != test.from || to != test.to {
+			t.Errorf("Parsed %q as %X-%X, expected %X-%X", test.text, from, to, test.from, test.to)
+		}
+	}
+}